@@ -0,0 +1,394 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ArtifactError reports every consistency problem found in an artifact
+type ArtifactError struct {
+	Problems []string
+}
+
+// Error lists the problems, one per line
+func (e *ArtifactError) Error() string {
+	return fmt.Sprintf("inconsistent artifact (%d problems):\n- %s", len(e.Problems), strings.Join(e.Problems, "\n- "))
+}
+
+// checkArtifact runs schema and consistency checks on a parsed artifact and
+// returns every problem found, in a stable order. An empty result means the
+// artifact is safe to encode and run.
+func checkArtifact(torchData *TorchModelData) []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if torchData.TorchModel.Model == "" && len(torchData.ModelBytes) == 0 {
+		report("torch_model.model is empty")
+	}
+	switch torchData.TorchModel.Compression {
+	case "", ModelCompressionGzip:
+	default:
+		report("torch_model.compression %q is not supported", torchData.TorchModel.Compression)
+	}
+	task, err := normalizeTaskType(torchData.TaskType)
+	if err != nil {
+		report("task_type: %v", err)
+	}
+	if math.IsNaN(torchData.ValidationTolerance) || torchData.ValidationTolerance < 0 {
+		report("validation_tolerance is %g, expected a non-negative number", torchData.ValidationTolerance)
+	}
+
+	problems = append(problems, checkFeatureInfo(torchData.FeatureInfo)...)
+	problems = append(problems, checkConfigs(torchData)...)
+	problems = append(problems, checkValidationData(torchData, task)...)
+	return problems
+}
+
+// checkFeatureInfo checks feature names, label encoders, vocabulary sizes
+// and transforms against each other
+func checkFeatureInfo(featureInfo FeatureInfo) []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	numerical := featureInfo.FeatureNames["numerical"]
+	categorical := featureInfo.FeatureNames["categorical"]
+	if featureInfo.NumNumericalFeatures != len(numerical) {
+		report("num_numerical_features is %d but feature_names.numerical lists %d features", featureInfo.NumNumericalFeatures, len(numerical))
+	}
+	if featureInfo.NumCategoricalFeatures != len(categorical) {
+		report("num_categorical_features is %d but feature_names.categorical lists %d features", featureInfo.NumCategoricalFeatures, len(categorical))
+	}
+	if len(numerical)+len(categorical) == 0 {
+		report("feature_names lists no features")
+	}
+
+	kinds := make(map[string]string)
+	for _, group := range []struct {
+		kind  string
+		names []string
+	}{{"numerical", numerical}, {"categorical", categorical}} {
+		for _, featureName := range group.names {
+			if featureName == "" {
+				report("feature_names.%s contains an empty name", group.kind)
+				continue
+			}
+			if previous, seen := kinds[featureName]; seen {
+				report("feature %s is listed twice (%s and %s)", featureName, previous, group.kind)
+				continue
+			}
+			kinds[featureName] = group.kind
+		}
+	}
+
+	encoders := featureInfo.MissingValueHandling.LabelEncoders
+	for _, featureName := range categorical {
+		encoder, exists := encoders[featureName]
+		if !exists {
+			report("categorical feature %s has no label encoder", featureName)
+		} else {
+			if len(encoder.Classes) == 0 {
+				report("label encoder of %s has no classes", featureName)
+			}
+			seen := make(map[string]bool, len(encoder.Classes))
+			for _, class := range encoder.Classes {
+				if seen[class] {
+					report("label encoder of %s has duplicate class %q", featureName, class)
+				}
+				seen[class] = true
+			}
+		}
+
+		vocabSize, exists := featureInfo.CategoricalVocabSizes[featureName]
+		switch {
+		case !exists:
+			report("categorical feature %s has no entry in categorical_vocab_sizes", featureName)
+		case encoder.Classes != nil && vocabSize != len(encoder.Classes):
+			report("categorical_vocab_sizes[%s] is %d but its label encoder has %d classes", featureName, vocabSize, len(encoder.Classes))
+		}
+	}
+
+	for _, featureName := range sortedKeys(featureInfo.CategoricalVocabSizes) {
+		if kinds[featureName] != "categorical" {
+			report("categorical_vocab_sizes has an entry for %s, which is not a categorical feature", featureName)
+		}
+	}
+	for featureName := range encoders {
+		if kinds[featureName] != "categorical" {
+			report("label_encoders has an encoder for %s, which is not a categorical feature", featureName)
+		}
+	}
+
+	for featureName, transforms := range featureInfo.Transforms {
+		if kinds[featureName] != "numerical" {
+			report("transforms are declared for %s, which is not a numerical feature", featureName)
+			continue
+		}
+		for i, transform := range transforms {
+			if err := checkTransform(transform); err != nil {
+				report("transform %d of %s (%s): %v", i+1, featureName, transform.Type, err)
+			}
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// checkTransform checks that a transform has the parameters it needs
+func checkTransform(transform FeatureTransform) error {
+	switch transform.Type {
+	case TransformStandardScaler:
+		if transform.Mean == nil || transform.Std == nil {
+			return fmt.Errorf("mean and std are required")
+		}
+		if *transform.Std < 0 {
+			return fmt.Errorf("std %g is negative", *transform.Std)
+		}
+	case TransformLog1p:
+		return nil
+	case TransformMinMax:
+		if transform.Min == nil || transform.Max == nil {
+			return fmt.Errorf("min and max are required")
+		}
+		if *transform.Min > *transform.Max {
+			return fmt.Errorf("min %g is greater than max %g", *transform.Min, *transform.Max)
+		}
+	case TransformClip:
+		if transform.Min == nil && transform.Max == nil {
+			return fmt.Errorf("min or max is required")
+		}
+		if transform.Min != nil && transform.Max != nil && *transform.Min > *transform.Max {
+			return fmt.Errorf("min %g is greater than max %g", *transform.Min, *transform.Max)
+		}
+	case TransformQuantileBuckets:
+		if len(transform.Boundaries) == 0 {
+			return fmt.Errorf("boundaries are required")
+		}
+		if !sort.Float64sAreSorted(transform.Boundaries) {
+			return fmt.Errorf("boundaries must be sorted ascending")
+		}
+	default:
+		return fmt.Errorf("unknown transform type")
+	}
+	return nil
+}
+
+// checkValidationData checks that the validation samples, the reference
+// predictions and the reference encodings line up
+func checkValidationData(torchData *TorchModelData, task string) []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	numSamples := len(torchData.ValidationData)
+	numPredictions := len(torchData.ValidationPredictions)
+	if torchData.ValidationSamples > 0 && torchData.ValidationSamples != numSamples {
+		report("validation_samples is %d but validation_data has %d samples", torchData.ValidationSamples, numSamples)
+	}
+	switch {
+	case task == TaskMulticlass && numSamples > 0:
+		if numPredictions%numSamples != 0 {
+			report("validation_predictions has %d scores, not a multiple of the %d validation samples", numPredictions, numSamples)
+		}
+	case numPredictions != numSamples:
+		report("validation_predictions has %d values but validation_data has %d samples", numPredictions, numSamples)
+	}
+
+	// Report each missing feature once, with the first sample lacking it
+	features := featureColumns(torchData.FeatureInfo)
+	firstMissing := make(map[string]int)
+	missingCount := make(map[string]int)
+	var order []string
+	weighted := 0
+	for i, sample := range torchData.ValidationData {
+		for _, featureName := range features {
+			if _, exists := sample[featureName]; exists {
+				continue
+			}
+			if missingCount[featureName] == 0 {
+				firstMissing[featureName] = i
+				order = append(order, featureName)
+			}
+			missingCount[featureName]++
+		}
+		if torchData.WeightColumn != "" {
+			if _, exists := sample[torchData.WeightColumn]; exists {
+				weighted++
+			}
+		}
+	}
+	for _, featureName := range order {
+		report("feature %s is missing from %d validation samples, first at sample %d", featureName, missingCount[featureName], firstMissing[featureName]+1)
+	}
+	if weighted > 0 && weighted < numSamples {
+		report("weight column %s is present in only %d of %d validation samples", torchData.WeightColumn, weighted, numSamples)
+	}
+
+	if encoded := torchData.ValidationEncodedInputs; encoded != nil {
+		numericalWidth := len(torchData.FeatureInfo.FeatureNames["numerical"])
+		categoricalWidth := len(torchData.FeatureInfo.FeatureNames["categorical"])
+		if len(encoded.Numerical) > 0 && len(encoded.Numerical) != numSamples {
+			report("validation_encoded_inputs.numerical has %d rows for %d samples", len(encoded.Numerical), numSamples)
+		}
+		if len(encoded.Categorical) > 0 && len(encoded.Categorical) != numSamples {
+			report("validation_encoded_inputs.categorical has %d rows for %d samples", len(encoded.Categorical), numSamples)
+		}
+		for i, row := range encoded.Numerical {
+			if len(row) != numericalWidth {
+				report("validation_encoded_inputs.numerical row %d has %d values, expected %d", i+1, len(row), numericalWidth)
+				break
+			}
+		}
+		for i, row := range encoded.Categorical {
+			if len(row) != categoricalWidth {
+				report("validation_encoded_inputs.categorical row %d has %d values, expected %d", i+1, len(row), categoricalWidth)
+				break
+			}
+		}
+	}
+
+	return problems
+}
+
+// embeddingTablePattern matches numbered weights such as
+// categorical_embeddings.3.weight, capturing the container and the index
+var embeddingTablePattern = regexp.MustCompile(`^(.*)\.(\d+)\.weight$`)
+
+// expectedVocabSizes returns the embedding table sizes the module should
+// have, in feature_names.categorical order: the config's
+// categorical_vocab_sizes when set, else the FeatureInfo's
+func expectedVocabSizes(featureInfo FeatureInfo, config *ModelConfig) []int {
+	if config.Has("categorical_vocab_sizes") {
+		return config.CategoricalVocabSizes
+	}
+	sizes := make([]int, 0, len(featureInfo.FeatureNames["categorical"]))
+	for _, featureName := range featureInfo.FeatureNames["categorical"] {
+		sizes = append(sizes, featureInfo.CategoricalVocabSizes[featureName])
+	}
+	return sizes
+}
+
+// findEmbeddingTables locates the module's per-feature embedding tables:
+// a container holding exactly one numbered 2-D weight per expected table.
+// A container whose shapes all match the expected sizes and embedding_dim
+// wins; otherwise the one with the most matching dimensions, or the only
+// one named like *embedding*, is returned for its mismatches to be
+// reported. An empty container means no tables could be told apart.
+func findEmbeddingTables(sizes []int, config *ModelConfig, params []ModuleParameter) (string, [][]int64) {
+	if len(sizes) == 0 {
+		return "", nil
+	}
+	tables := make(map[string]map[int][]int64)
+	var containers []string
+	for _, param := range params {
+		match := embeddingTablePattern.FindStringSubmatch(param.Name)
+		if match == nil || len(param.Shape) != 2 {
+			continue
+		}
+		index, err := strconv.Atoi(match[2])
+		if err != nil {
+			continue
+		}
+		if tables[match[1]] == nil {
+			tables[match[1]] = make(map[int][]int64)
+			containers = append(containers, match[1])
+		}
+		tables[match[1]][index] = param.Shape
+	}
+
+	var best, named []string
+	bestScore := 0
+	shapes := make(map[string][][]int64)
+	for _, container := range containers {
+		if len(tables[container]) != len(sizes) {
+			continue
+		}
+		ordered := make([][]int64, len(sizes))
+		complete := true
+		for i := range sizes {
+			if ordered[i], complete = tables[container][i]; !complete {
+				break
+			}
+		}
+		if !complete {
+			continue
+		}
+		score := 0
+		for i, shape := range ordered {
+			if shape[0] == int64(sizes[i]) {
+				score++
+			}
+			if !config.Has("embedding_dim") || shape[1] == int64(config.EmbeddingDim) {
+				score++
+			}
+		}
+		if score == 2*len(sizes) {
+			return container, ordered
+		}
+		shapes[container] = ordered
+		if score > bestScore {
+			best, bestScore = []string{container}, score
+		} else if score == bestScore {
+			best = append(best, container)
+		}
+		name := container[strings.LastIndex(container, ".")+1:]
+		if strings.Contains(strings.ToLower(name), "embedding") {
+			named = append(named, container)
+		}
+	}
+
+	switch {
+	case len(best) == 1 && bestScore > 0:
+		return best[0], shapes[best[0]]
+	case len(named) == 1:
+		return named[0], shapes[named[0]]
+	}
+	return "", nil
+}
+
+// checkEmbeddingShapes cross-checks the expected embedding table sizes, in
+// the order of the config's categorical_vocab_sizes, and embedding_dim
+// against the module's embedding tables. It reports whether the tables
+// were found.
+func checkEmbeddingShapes(featureInfo FeatureInfo, config *ModelConfig, params []ModuleParameter) ([]string, bool) {
+	sizes := expectedVocabSizes(featureInfo, config)
+	container, shapes := findEmbeddingTables(sizes, config, params)
+	if container == "" {
+		return nil, false
+	}
+
+	categorical := featureInfo.FeatureNames["categorical"]
+	var problems []string
+	for i, shape := range shapes {
+		table := fmt.Sprintf("table %d", i)
+		if i < len(categorical) {
+			table = fmt.Sprintf("table of %s", categorical[i])
+		}
+		if shape[0] != int64(sizes[i]) {
+			problems = append(problems, fmt.Sprintf("%s expects %d rows but module table %s.%d has %d", table, sizes[i], container, i, shape[0]))
+		}
+		if config.Has("embedding_dim") && shape[1] != int64(config.EmbeddingDim) {
+			problems = append(problems, fmt.Sprintf("torch_model.config.embedding_dim is %d but module table %s.%d has width %d", config.EmbeddingDim, container, i, shape[1]))
+		}
+	}
+	return problems, true
+}
+
+// sortedKeys returns the keys of a map in ascending order
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files of a model directory: a plain TorchScript archive with sidecar
+// metadata, as an alternative to the embedded model.json format
+const (
+	bundleModelFile           = "model.pt"
+	bundleFeatureInfoFile     = "feature_info.json"
+	bundleMetadataFile        = "metadata.json"
+	bundleValidationFile      = "validation.jsonl"
+	bundleTrainingHistoryFile = "training_history.json"
+)
+
+// bundleMetadata holds the artifact fields stored in metadata.json that are
+// not part of TorchModelData
+type bundleMetadata struct {
+	Interval int `json:"interval"`
+}
+
+// bundleValidationSample is one line of validation.jsonl: the raw features
+// and the Python output, a number or, for multiclass, one score per class
+type bundleValidationSample struct {
+	Features   ValidationData  `json:"features"`
+	Prediction json.RawMessage `json:"prediction"`
+}
+
+// bundleValidationLine is a validation.jsonl line with verbatim values
+type bundleValidationLine struct {
+	Features   json.RawMessage `json:"features"`
+	Prediction json.RawMessage `json:"prediction"`
+}
+
+// isModelBundle reports whether path names a model directory or a plain
+// TorchScript file rather than a model.json artifact
+func isModelBundle(path string) bool {
+	if strings.EqualFold(filepath.Ext(path), ".pt") {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// loadModelBundle loads a model directory holding model.pt and
+// feature_info.json, plus optional metadata.json (task type, hyperparameters
+// and other TorchModelData fields), validation.jsonl and
+// training_history.json. path may also name the .pt file itself, with the
+// sidecar files next to it.
+func loadModelBundle(path string) (*ModelData, *TorchModelData, error) {
+	dir, modelPath := path, filepath.Join(path, bundleModelFile)
+	if strings.EqualFold(filepath.Ext(path), ".pt") {
+		dir, modelPath = filepath.Dir(path), path
+	}
+
+	var modelData ModelData
+	var torchData TorchModelData
+	metadata, err := os.ReadFile(filepath.Join(dir, bundleMetadataFile))
+	switch {
+	case err == nil:
+		var header schemaHeader
+		if err := json.Unmarshal(metadata, &header); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", bundleMetadataFile, err)
+		}
+		if err := checkSchemaVersion(header.SchemaVersion); err != nil {
+			return nil, nil, err
+		}
+		if err := json.Unmarshal(metadata, &torchData); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", bundleMetadataFile, err)
+		}
+		var extra bundleMetadata
+		if err := json.Unmarshal(metadata, &extra); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", bundleMetadataFile, err)
+		}
+		modelData.Interval = extra.Interval
+		if torchData.Extra, err = extraFields(metadata, "interval"); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", bundleMetadataFile, err)
+		}
+		if torchData.Encryption != nil {
+			return nil, nil, fmt.Errorf("%s declares encryption, which model directories do not support", bundleMetadataFile)
+		}
+	case !os.IsNotExist(err):
+		return nil, nil, fmt.Errorf("failed to read %s: %w", bundleMetadataFile, err)
+	}
+
+	featureInfo, err := os.ReadFile(filepath.Join(dir, bundleFeatureInfoFile))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", bundleFeatureInfoFile, err)
+	}
+	torchData.FeatureInfo = FeatureInfo{}
+	if err := json.Unmarshal(featureInfo, &torchData.FeatureInfo); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", bundleFeatureInfoFile, err)
+	}
+	if err := applyFeatureInfoMigration(&torchData, featureInfo); err != nil {
+		return nil, nil, err
+	}
+
+	torchData.ModelBytes, err = os.ReadFile(modelPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read model: %w", err)
+	}
+	torchData.TorchModel.Model = ""
+
+	trainingHistory, err := os.ReadFile(filepath.Join(dir, bundleTrainingHistoryFile))
+	switch {
+	case err == nil:
+		torchData.TrainingHistory = TrainingHistory{}
+		if err := json.Unmarshal(trainingHistory, &torchData.TrainingHistory); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %w", bundleTrainingHistoryFile, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, nil, fmt.Errorf("failed to read %s: %w", bundleTrainingHistoryFile, err)
+	}
+
+	validationPath := filepath.Join(dir, bundleValidationFile)
+	if _, err := os.Stat(validationPath); err == nil {
+		torchData.ValidationData, torchData.ValidationPredictions, err = readBundleValidation(validationPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", bundleValidationFile, err)
+		}
+	}
+
+	return &modelData, &torchData, nil
+}
+
+// readBundleValidation reads validation samples and their Python outputs.
+// Features are decoded like the embedded validation_data, so a directory and
+// a model.json encode the same samples identically.
+func readBundleValidation(filePath string) ([]ValidationData, []float64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	samples := []ValidationData{}
+	predictions := []float64{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var sample bundleValidationSample
+		if err := json.Unmarshal(text, &sample); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if sample.Features == nil {
+			return nil, nil, fmt.Errorf("line %d: no features", line)
+		}
+
+		var scores []float64
+		switch {
+		case len(sample.Prediction) == 0:
+			return nil, nil, fmt.Errorf("line %d: no prediction", line)
+		case sample.Prediction[0] == '[':
+			if err := json.Unmarshal(sample.Prediction, &scores); err != nil {
+				return nil, nil, fmt.Errorf("line %d: prediction: %w", line, err)
+			}
+		default:
+			var score float64
+			if err := json.Unmarshal(sample.Prediction, &score); err != nil {
+				return nil, nil, fmt.Errorf("line %d: prediction: %w", line, err)
+			}
+			scores = []float64{score}
+		}
+
+		samples = append(samples, sample.Features)
+		predictions = append(predictions, scores...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return samples, predictions, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// CanonicalizationRule describes how raw values of a categorical feature
+// are rewritten before label encoding. Rules only apply to values that are
+// not already encoder classes, so clean data is never changed.
+type CanonicalizationRule struct {
+	// Trim removes leading and trailing whitespace
+	Trim bool `json:"trim,omitempty"`
+	// CaseFold matches values against encoder classes case-insensitively
+	CaseFold bool `json:"case_fold,omitempty"`
+	// CountryAlpha3 maps ISO 3166-1 alpha-3 country codes to alpha-2
+	CountryAlpha3 bool `json:"country_alpha3,omitempty"`
+	// Aliases maps raw values to encoder classes
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// Canonicalizer rewrites categorical values to their encoder class and
+// counts every rewrite per feature
+type Canonicalizer struct {
+	rules    map[string]CanonicalizationRule
+	rewrites map[string]map[string]int
+}
+
+// defaultCanonicalizationRules trims and case-folds every categorical
+// feature, and maps alpha-3 country codes for geo
+func defaultCanonicalizationRules(featureInfo FeatureInfo) map[string]CanonicalizationRule {
+	rules := make(map[string]CanonicalizationRule)
+	for _, featureName := range featureInfo.FeatureNames["categorical"] {
+		rules[featureName] = CanonicalizationRule{
+			Trim:          true,
+			CaseFold:      true,
+			CountryAlpha3: featureName == "geo",
+		}
+	}
+	return rules
+}
+
+// canonicalizationOverride is a rule read from a rules file. Fields left out
+// keep the default rule's setting.
+type canonicalizationOverride struct {
+	Trim          *bool             `json:"trim"`
+	CaseFold      *bool             `json:"case_fold"`
+	CountryAlpha3 *bool             `json:"country_alpha3"`
+	Aliases       map[string]string `json:"aliases"`
+}
+
+// loadCanonicalizationRules reads per-feature rules from a JSON file and
+// merges them field by field into the defaults
+func loadCanonicalizationRules(filePath string, featureInfo FeatureInfo) (map[string]CanonicalizationRule, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read canonicalization rules: %w", err)
+	}
+
+	var overrides map[string]canonicalizationOverride
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse canonicalization rules: %w", err)
+	}
+
+	rules := defaultCanonicalizationRules(featureInfo)
+	for featureName, override := range overrides {
+		rule := rules[featureName]
+		if override.Trim != nil {
+			rule.Trim = *override.Trim
+		}
+		if override.CaseFold != nil {
+			rule.CaseFold = *override.CaseFold
+		}
+		if override.CountryAlpha3 != nil {
+			rule.CountryAlpha3 = *override.CountryAlpha3
+		}
+		if len(override.Aliases) > 0 {
+			aliases := make(map[string]string, len(rule.Aliases)+len(override.Aliases))
+			for from, to := range rule.Aliases {
+				aliases[from] = to
+			}
+			for from, to := range override.Aliases {
+				aliases[from] = to
+			}
+			rule.Aliases = aliases
+		}
+		rules[featureName] = rule
+	}
+	return rules, nil
+}
+
+// newCanonicalizer creates a canonicalizer from per-feature rules
+func newCanonicalizer(rules map[string]CanonicalizationRule) *Canonicalizer {
+	return &Canonicalizer{
+		rules:    rules,
+		rewrites: make(map[string]map[string]int),
+	}
+}
+
+// Canonicalize returns the encoder class for a raw value, or the value
+// unchanged if no rule maps it to a known class
+func (c *Canonicalizer) Canonicalize(featureName string, value string, encoder LabelEncoder) string {
+	rule, exists := c.rules[featureName]
+	if !exists || containsClass(encoder, value) {
+		return value
+	}
+
+	candidate := value
+	if rule.Trim {
+		candidate = strings.TrimSpace(candidate)
+	}
+	if alias, ok := rule.Aliases[candidate]; ok {
+		candidate = alias
+	}
+	if rule.CountryAlpha3 {
+		if alpha2, ok := countryAlpha3ToAlpha2[strings.ToUpper(candidate)]; ok {
+			candidate = alpha2
+		}
+	}
+	if rule.CaseFold && !containsClass(encoder, candidate) {
+		for _, class := range encoder.Classes {
+			if strings.EqualFold(class, candidate) {
+				candidate = class
+				break
+			}
+		}
+	}
+
+	if candidate == value || !containsClass(encoder, candidate) {
+		return value
+	}
+
+	c.countRewrite(featureName, value, candidate)
+	return candidate
+}
+
+// countRewrite records a rewrite of from to to for a feature
+func (c *Canonicalizer) countRewrite(featureName string, from string, to string) {
+	counts, exists := c.rewrites[featureName]
+	if !exists {
+		counts = make(map[string]int)
+		c.rewrites[featureName] = counts
+	}
+	counts[fmt.Sprintf("%q -> %q", from, to)]++
+}
+
+// PrintSummary prints the number of rewrites per feature and value
+func (c *Canonicalizer) PrintSummary() {
+	if len(c.rewrites) == 0 {
+		fmt.Printf("No categorical values were canonicalized\n")
+		return
+	}
+
+	featureNames := make([]string, 0, len(c.rewrites))
+	for featureName := range c.rewrites {
+		featureNames = append(featureNames, featureName)
+	}
+	sort.Strings(featureNames)
+
+	for _, featureName := range featureNames {
+		counts := c.rewrites[featureName]
+		rewrites := make([]string, 0, len(counts))
+		total := 0
+		for rewrite, count := range counts {
+			rewrites = append(rewrites, rewrite)
+			total += count
+		}
+		sort.Strings(rewrites)
+
+		fmt.Printf("- %s: %d rewrites\n", featureName, total)
+		for _, rewrite := range rewrites {
+			fmt.Printf("    %s (%d)\n", rewrite, counts[rewrite])
+		}
+	}
+}
+
+// containsClass reports whether value is one of the encoder classes
+func containsClass(encoder LabelEncoder, value string) bool {
+	for _, class := range encoder.Classes {
+		if class == value {
+			return true
+		}
+	}
+	return false
+}
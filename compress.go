@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ModelCompressionGzip marks a TorchModel.Model payload as base64 of
+// gzip-compressed TorchScript
+const ModelCompressionGzip = "gzip"
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// artifactFile is an open artifact, transparently decompressed
+type artifactFile struct {
+	io.Reader
+	file *os.File
+	gzip *gzip.Reader
+}
+
+// Close closes the decompressor and the file
+func (a *artifactFile) Close() error {
+	if a.gzip != nil {
+		a.gzip.Close()
+	}
+	return a.file.Close()
+}
+
+// openArtifact opens an artifact file, decompressing it when it starts with
+// the gzip magic bytes. It also returns the uncompressed size in bytes, or 0
+// if unknown, as a hint for sizing buffers.
+func openArtifact(filePath string) (io.ReadCloser, int64, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var size int64
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if !bytes.Equal(magic, gzipMagic) {
+		if strings.EqualFold(filepath.Ext(filePath), ".gz") {
+			file.Close()
+			return nil, 0, fmt.Errorf("%s has a .gz extension but is not gzip-compressed", filePath)
+		}
+		return &artifactFile{Reader: buffered, file: file}, size, nil
+	}
+
+	size = gzipUncompressedSize(file, size)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to read gzip header: %w", err)
+	}
+	return &artifactFile{Reader: reader, file: file, gzip: reader}, size, nil
+}
+
+// gzipUncompressedSize reads the uncompressed size from the gzip trailer.
+// The trailer holds the size modulo 2^32 of the last member only, so the
+// result is a hint.
+func gzipUncompressedSize(file *os.File, compressedSize int64) int64 {
+	if compressedSize < 4 {
+		return 0
+	}
+	var trailer [4]byte
+	if _, err := file.ReadAt(trailer[:], compressedSize-4); err != nil {
+		return 0
+	}
+	return int64(binary.LittleEndian.Uint32(trailer[:]))
+}
+
+// readArtifactFile reads a whole artifact file, decompressing it if needed
+func readArtifactFile(filePath string) ([]byte, error) {
+	reader, sizeHint, err := openArtifact(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	buffer := bytes.NewBuffer(make([]byte, 0, sizeHint))
+	if _, err := io.Copy(buffer, reader); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// writeArtifactFile writes an artifact file, gzip-compressed when the path
+// ends in .gz
+func writeArtifactFile(filePath string, data []byte) error {
+	if !strings.EqualFold(filepath.Ext(filePath), ".gz") {
+		return os.WriteFile(filePath, data, 0644)
+	}
+	compressed, err := gzipBytes(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, compressed, 0644)
+}
+
+// decodeModelPayload decodes a base64 model payload and decompresses it
+func decodeModelPayload(payload string, compression string) ([]byte, error) {
+	modelBytes, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode model: %w", err)
+	}
+	return decompressModel(modelBytes, compression)
+}
+
+// decompressModel undoes the compression of a decoded model payload
+func decompressModel(payload []byte, compression string) ([]byte, error) {
+	switch compression {
+	case "":
+		return payload, nil
+	case ModelCompressionGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress model: %w", err)
+		}
+		defer reader.Close()
+		model, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress model: %w", err)
+		}
+		return model, nil
+	default:
+		return nil, fmt.Errorf("unknown model compression %q", compression)
+	}
+}
+
+// compressModel compresses a model payload before it is base64-encoded
+func compressModel(model []byte, compression string) ([]byte, error) {
+	switch compression {
+	case "":
+		return model, nil
+	case ModelCompressionGzip:
+		return gzipBytes(model)
+	default:
+		return nil, fmt.Errorf("unknown model compression %q", compression)
+	}
+}
+
+// gzipBytes compresses data with the best gzip compression
+func gzipBytes(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	writer, err := gzip.NewWriterLevel(&out, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
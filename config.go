@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// ModelConfig is the architecture described by TorchModel.Config, the
+// keyword arguments the Python side built the module with
+type ModelConfig struct {
+	NumNumericalFeatures   int    `json:"num_numerical_features"`
+	NumCategoricalFeatures int    `json:"num_categorical_features"`
+	TaskType               string `json:"task_type"`
+	// CategoricalVocabSizes holds one embedding table size per categorical
+	// feature, in feature_names.categorical order
+	CategoricalVocabSizes []int   `json:"categorical_vocab_sizes"`
+	EmbeddingDim          int     `json:"embedding_dim"`
+	DropoutRate           float64 `json:"dropout_rate"`
+	HiddenDims            []int   `json:"hidden_dims"`
+	NumClasses            int     `json:"num_classes"`
+	// OutputActivation is the activation applied to the module outputs:
+	// sigmoid or softmax for probabilities, none for logits
+	OutputActivation string `json:"output_activation"`
+
+	// fields holds every key of the config as written, to tell absent keys
+	// from zero values
+	fields map[string]json.RawMessage
+}
+
+// TrainingConfig is the training setup recorded in TrainingHistory.Config
+type TrainingConfig struct {
+	Epochs       int     `json:"epochs"`
+	LearningRate float64 `json:"learning_rate"`
+	WeightDecay  float64 `json:"weight_decay"`
+	BatchSize    int     `json:"batch_size"`
+
+	fields map[string]json.RawMessage
+}
+
+// parseModelConfig parses TorchModel.Config. An empty config yields an
+// empty ModelConfig.
+func parseModelConfig(config string) (*ModelConfig, error) {
+	var modelConfig ModelConfig
+	if config == "" {
+		return &modelConfig, nil
+	}
+	if err := json.Unmarshal([]byte(config), &modelConfig.fields); err != nil {
+		return nil, fmt.Errorf("torch_model.config is not a JSON object: %w", err)
+	}
+	if err := json.Unmarshal([]byte(config), &modelConfig); err != nil {
+		return nil, fmt.Errorf("torch_model.config: %w", err)
+	}
+	return &modelConfig, nil
+}
+
+// parseTrainingConfig parses TrainingHistory.Config
+func parseTrainingConfig(config map[string]interface{}) (*TrainingConfig, error) {
+	var trainingConfig TrainingConfig
+	if len(config) == 0 {
+		return &trainingConfig, nil
+	}
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("training_history.config: %w", err)
+	}
+	if err := json.Unmarshal(data, &trainingConfig.fields); err != nil {
+		return nil, fmt.Errorf("training_history.config: %w", err)
+	}
+	if err := json.Unmarshal(data, &trainingConfig); err != nil {
+		return nil, fmt.Errorf("training_history.config: %w", err)
+	}
+	return &trainingConfig, nil
+}
+
+// Has reports whether the config sets key
+func (c *ModelConfig) Has(key string) bool {
+	_, exists := c.fields[key]
+	return exists
+}
+
+// Extra returns the config keys this tool does not interpret
+func (c *ModelConfig) Extra() map[string]json.RawMessage {
+	return unknownFields(c.fields, c)
+}
+
+// Has reports whether the config sets key
+func (c *TrainingConfig) Has(key string) bool {
+	_, exists := c.fields[key]
+	return exists
+}
+
+// Extra returns the config keys this tool does not interpret
+func (c *TrainingConfig) Extra() map[string]json.RawMessage {
+	return unknownFields(c.fields, c)
+}
+
+// unknownFields returns the fields not matching a JSON tag of config
+func unknownFields(fields map[string]json.RawMessage, config interface{}) map[string]json.RawMessage {
+	known, _ := json.Marshal(config)
+	var knownFields map[string]json.RawMessage
+	json.Unmarshal(known, &knownFields)
+
+	extra := make(map[string]json.RawMessage)
+	for key, value := range fields {
+		if _, exists := knownFields[key]; !exists {
+			extra[key] = value
+		}
+	}
+	return extra
+}
+
+// checkConfigs parses the model and training configs and cross-checks them
+// against the FeatureInfo and the top-level hyperparameters. Only keys the
+// configs set are checked.
+func checkConfigs(torchData *TorchModelData) []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	modelConfig, err := parseModelConfig(torchData.TorchModel.Config)
+	if err != nil {
+		report("%v", err)
+	} else {
+		problems = append(problems, checkModelConfig(modelConfig, torchData)...)
+		problems = append(problems, checkModelSpecificKwargs(modelConfig, torchData.ModelSpecificKwargs)...)
+	}
+
+	trainingConfig, err := parseTrainingConfig(torchData.TrainingHistory.Config)
+	if err != nil {
+		report("%v", err)
+		return problems
+	}
+	if trainingConfig.Has("epochs") && trainingConfig.Epochs != torchData.Epochs {
+		report("training_history.config.epochs is %d but epochs is %d", trainingConfig.Epochs, torchData.Epochs)
+	}
+	if trainingConfig.Has("learning_rate") && trainingConfig.LearningRate != torchData.LearningRate {
+		report("training_history.config.learning_rate is %g but learning_rate is %g", trainingConfig.LearningRate, torchData.LearningRate)
+	}
+	if trainingConfig.Has("weight_decay") && trainingConfig.WeightDecay != torchData.WeightDecay {
+		report("training_history.config.weight_decay is %g but weight_decay is %g", trainingConfig.WeightDecay, torchData.WeightDecay)
+	}
+	if trainingConfig.Has("batch_size") && trainingConfig.BatchSize != torchData.BatchSize {
+		report("training_history.config.batch_size is %d but batch_size is %d", trainingConfig.BatchSize, torchData.BatchSize)
+	}
+	if trainingConfig.Has("epochs") && len(torchData.TrainingHistory.TrainLosses) > trainingConfig.Epochs {
+		report("training_history.train_losses has %d entries for %d epochs", len(torchData.TrainingHistory.TrainLosses), trainingConfig.Epochs)
+	}
+	return problems
+}
+
+// checkModelSpecificKwargs cross-checks the top-level model_specific_kwargs
+// against torch_model.config. Keys the config does not set are not checked.
+func checkModelSpecificKwargs(config *ModelConfig, kwargs map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(kwargs))
+	for key := range kwargs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var problems []string
+	for _, key := range keys {
+		configValue, exists := config.fields[key]
+		if exists && !jsonValuesEqual(kwargs[key], configValue) {
+			problems = append(problems, fmt.Sprintf("model_specific_kwargs.%s is %s but torch_model.config.%s is %s", key, kwargs[key], key, configValue))
+		}
+	}
+	return problems
+}
+
+// jsonValuesEqual compares two JSON values by value, so 0 equals 0.0
+func jsonValuesEqual(a json.RawMessage, b json.RawMessage) bool {
+	var left, right interface{}
+	if json.Unmarshal(a, &left) != nil || json.Unmarshal(b, &right) != nil {
+		return false
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// checkModelConfig cross-checks the architecture against the FeatureInfo:
+// one embedding table per categorical feature, sized like its vocabulary
+func checkModelConfig(config *ModelConfig, torchData *TorchModelData) []string {
+	var problems []string
+	report := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	featureInfo := torchData.FeatureInfo
+	numerical := featureInfo.FeatureNames["numerical"]
+	categorical := featureInfo.FeatureNames["categorical"]
+	if config.Has("num_numerical_features") && config.NumNumericalFeatures != len(numerical) {
+		report("torch_model.config.num_numerical_features is %d but feature_names.numerical lists %d features", config.NumNumericalFeatures, len(numerical))
+	}
+	if config.Has("num_categorical_features") && config.NumCategoricalFeatures != len(categorical) {
+		report("torch_model.config.num_categorical_features is %d but feature_names.categorical lists %d features", config.NumCategoricalFeatures, len(categorical))
+	}
+	if config.Has("categorical_vocab_sizes") {
+		if len(config.CategoricalVocabSizes) != len(categorical) {
+			report("torch_model.config has %d embedding tables but the artifact has %d categorical features", len(config.CategoricalVocabSizes), len(categorical))
+		} else {
+			for i, featureName := range categorical {
+				vocabSize, exists := featureInfo.CategoricalVocabSizes[featureName]
+				if exists && config.CategoricalVocabSizes[i] != vocabSize {
+					report("torch_model.config.categorical_vocab_sizes[%d] is %d but categorical_vocab_sizes[%s] is %d", i, config.CategoricalVocabSizes[i], featureName, vocabSize)
+				}
+			}
+		}
+	}
+	if config.Has("task_type") {
+		configTask, configErr := normalizeTaskType(config.TaskType)
+		task, err := normalizeTaskType(torchData.TaskType)
+		switch {
+		case configErr != nil:
+			report("torch_model.config.task_type: %v", configErr)
+		case err == nil && configTask != task:
+			report("torch_model.config.task_type is %q but task_type is %q", config.TaskType, torchData.TaskType)
+		}
+	}
+
+	if config.OutputActivation != "" {
+		if _, err := activationScoreScale(config.OutputActivation); err != nil {
+			report("torch_model.config.output_activation: %v", err)
+		}
+	}
+	if config.Has("embedding_dim") && config.EmbeddingDim <= 0 {
+		report("torch_model.config.embedding_dim is %d, expected a positive number", config.EmbeddingDim)
+	}
+	if config.Has("dropout_rate") && (math.IsNaN(config.DropoutRate) || config.DropoutRate < 0 || config.DropoutRate >= 1) {
+		report("torch_model.config.dropout_rate is %g, expected a number in [0, 1)", config.DropoutRate)
+	}
+	for i, dim := range config.HiddenDims {
+		if dim <= 0 {
+			report("torch_model.config.hidden_dims[%d] is %d, expected a positive number", i, dim)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// loadDataset reads samples from a JSONL or CSV file, chosen by extension.
+// CSV files must have a header row; their values are kept as strings.
+func loadDataset(filePath string) ([]ValidationData, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv":
+		return readCSVDataset(file)
+	case ".jsonl", ".ndjson", ".json":
+		return readJSONLDataset(file)
+	default:
+		return nil, fmt.Errorf("unsupported dataset format %q (expected .jsonl or .csv)", filepath.Ext(filePath))
+	}
+}
+
+// readJSONLDataset reads one JSON object per line. Numbers are kept as
+// json.Number so large integer IDs stay exact.
+func readJSONLDataset(r io.Reader) ([]ValidationData, error) {
+	var samples []ValidationData
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+		var sample ValidationData
+		if err := decoder.Decode(&sample); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+	return samples, nil
+}
+
+// readCSVDataset reads a CSV file with a header row
+func readCSVDataset(r io.Reader) ([]ValidationData, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	var samples []ValidationData
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		sample := make(ValidationData, len(header))
+		for i, column := range header {
+			sample[column] = record[i]
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// hasColumn reports whether any sample carries the column
+func hasColumn(samples []ValidationData, column string) bool {
+	for _, sample := range samples {
+		if _, exists := sample[column]; exists {
+			return true
+		}
+	}
+	return false
+}
+
+// extractColumn converts a numeric column of every sample to float64
+func extractColumn(samples []ValidationData, column string) ([]float64, error) {
+	values := make([]float64, len(samples))
+	for i, sample := range samples {
+		value, err := getFeatureValue(sample, column)
+		if err != nil {
+			return nil, fmt.Errorf("sample %d: %v", i+1, err)
+		}
+		values[i], err = convertToFloat64(value)
+		if err != nil {
+			return nil, fmt.Errorf("sample %d: invalid %s: %v", i+1, column, err)
+		}
+	}
+	return values, nil
+}
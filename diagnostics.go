@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+)
+
+// numericalEncodingTolerance is the relative difference allowed between a
+// Go-encoded numerical input and the Python reference
+const numericalEncodingTolerance = 1e-6
+
+// EncodingDivergence is one encoded input that differs from the reference
+type EncodingDivergence struct {
+	Sample  int
+	Feature string
+	Kind    string
+	Raw     interface{}
+	Got     float64
+	Want    float64
+}
+
+// compareEncodings compares Go-encoded inputs with the Python reference and
+// returns every divergence, ordered by sample and then by feature
+func compareEncodings(batch *EncodedBatch, reference *EncodedInputs, samples []ValidationData) ([]EncodingDivergence, error) {
+	if len(reference.Numerical) > 0 && len(reference.Numerical) != batch.NumSamples {
+		return nil, fmt.Errorf("reference has %d numerical rows for %d samples", len(reference.Numerical), batch.NumSamples)
+	}
+	if len(reference.Categorical) > 0 && len(reference.Categorical) != batch.NumSamples {
+		return nil, fmt.Errorf("reference has %d categorical rows for %d samples", len(reference.Categorical), batch.NumSamples)
+	}
+
+	var divergences []EncodingDivergence
+	for i := 0; i < batch.NumSamples; i++ {
+		if len(reference.Numerical) > 0 {
+			want := reference.Numerical[i]
+			if len(want) != len(batch.NumericalFeatures) {
+				return nil, fmt.Errorf("sample %d: reference has %d numerical features, model has %d", i+1, len(want), len(batch.NumericalFeatures))
+			}
+			for j, got := range batch.NumericalRow(i) {
+				if numericalEncodingDiverges(float64(got), want[j]) {
+					featureName := batch.NumericalFeatures[j]
+					divergences = append(divergences, EncodingDivergence{
+						Sample: i, Feature: featureName, Kind: "numerical",
+						Raw: samples[i][featureName], Got: float64(got), Want: want[j],
+					})
+				}
+			}
+		}
+
+		if len(reference.Categorical) > 0 {
+			want := reference.Categorical[i]
+			if len(want) != len(batch.CategoricalFeatures) {
+				return nil, fmt.Errorf("sample %d: reference has %d categorical features, model has %d", i+1, len(want), len(batch.CategoricalFeatures))
+			}
+			for j, got := range batch.CategoricalRow(i) {
+				if got != want[j] {
+					featureName := batch.CategoricalFeatures[j]
+					divergences = append(divergences, EncodingDivergence{
+						Sample: i, Feature: featureName, Kind: "categorical",
+						Raw: samples[i][featureName], Got: float64(got), Want: float64(want[j]),
+					})
+				}
+			}
+		}
+	}
+	return divergences, nil
+}
+
+// numericalEncodingDiverges reports whether an encoded numerical input is
+// outside the tolerance of the reference. NaN only matches NaN and an
+// infinity only the same infinity.
+func numericalEncodingDiverges(got float64, want float64) bool {
+	if math.IsNaN(got) || math.IsNaN(want) {
+		return math.IsNaN(got) != math.IsNaN(want)
+	}
+	if math.IsInf(got, 0) || math.IsInf(want, 0) {
+		return got != want
+	}
+	return math.Abs(got-want) > numericalEncodingTolerance*math.Max(1, math.Abs(want))
+}
+
+// printEncodedDump prints the encoded inputs of every sample next to the
+// raw values and, when available, the reference encoding
+func printEncodedDump(w io.Writer, batch *EncodedBatch, samples []ValidationData, reference *EncodedInputs) {
+	for i := 0; i < batch.NumSamples; i++ {
+		fmt.Fprintf(w, "\nSample %d:\n", i+1)
+		fmt.Fprintf(w, "  %-24s %-28s %-14s %-14s\n", "Feature", "Raw", "Encoded", "Reference")
+		for j, featureName := range batch.NumericalFeatures {
+			want := "-"
+			if reference != nil && i < len(reference.Numerical) && j < len(reference.Numerical[i]) {
+				want = fmt.Sprintf("%g", reference.Numerical[i][j])
+			}
+			fmt.Fprintf(w, "  %-24s %-28s %-14g %-14s\n", truncateString(featureName, 24),
+				truncateString(fmt.Sprintf("%v", samples[i][featureName]), 28), batch.NumericalRow(i)[j], want)
+		}
+		for j, featureName := range batch.CategoricalFeatures {
+			want := "-"
+			if reference != nil && i < len(reference.Categorical) && j < len(reference.Categorical[i]) {
+				want = fmt.Sprintf("%d", reference.Categorical[i][j])
+			}
+			fmt.Fprintf(w, "  %-24s %-28s %-14d %-14s\n", truncateString(featureName, 24),
+				truncateString(fmt.Sprintf("%v", samples[i][featureName]), 28), batch.CategoricalRow(i)[j], want)
+		}
+	}
+}
+
+// printDivergenceSummary points at the first diverging sample and feature
+// and lists the first divergence of every affected feature
+func printDivergenceSummary(divergences []EncodingDivergence) {
+	if len(divergences) == 0 {
+		fmt.Printf("✅ Go encodings match the Python reference encodings for every sample\n")
+		return
+	}
+
+	first := divergences[0]
+	fmt.Printf("❌ First divergence: sample %d, %s feature %s: raw %v encoded as %g, Python encoded %g\n",
+		first.Sample+1, first.Kind, first.Feature, first.Raw, first.Got, first.Want)
+
+	counts := make(map[string]int)
+	var order []string
+	firstByFeature := make(map[string]EncodingDivergence)
+	for _, d := range divergences {
+		if _, seen := counts[d.Feature]; !seen {
+			order = append(order, d.Feature)
+			firstByFeature[d.Feature] = d
+		}
+		counts[d.Feature]++
+	}
+
+	fmt.Printf("\nDiverging features (%d):\n", len(order))
+	for _, featureName := range order {
+		d := firstByFeature[featureName]
+		fmt.Printf("- %s: %d samples, first at sample %d (raw %v: Go %g, Python %g)\n",
+			featureName, counts[featureName], d.Sample+1, d.Raw, d.Got, d.Want)
+	}
+}
+
+// encodedDumpSample is one sample in the JSON encoded-tensor dump.
+// Non-finite numerical inputs are null.
+type encodedDumpSample struct {
+	Index       int            `json:"index"`
+	Raw         ValidationData `json:"raw"`
+	Numerical   []*float32     `json:"numerical,omitempty"`
+	Categorical []int64        `json:"categorical,omitempty"`
+}
+
+// writeEncodedDump writes the encoded inputs of every sample as JSON
+func writeEncodedDump(filePath string, batch *EncodedBatch, samples []ValidationData) error {
+	dump := struct {
+		NumericalFeatures   []string            `json:"numerical_features"`
+		CategoricalFeatures []string            `json:"categorical_features"`
+		Samples             []encodedDumpSample `json:"samples"`
+	}{
+		NumericalFeatures:   batch.NumericalFeatures,
+		CategoricalFeatures: batch.CategoricalFeatures,
+	}
+	for i := 0; i < batch.NumSamples; i++ {
+		sample := encodedDumpSample{
+			Index:       i,
+			Raw:         samples[i],
+			Categorical: batch.CategoricalRow(i),
+		}
+		for _, v := range batch.NumericalRow(i) {
+			var value *float32
+			if !math.IsNaN(float64(v)) && !math.IsInf(float64(v), 0) {
+				value = new(float32)
+				*value = v
+			}
+			sample.Numerical = append(sample.Numerical, value)
+		}
+		dump.Samples = append(dump.Samples, sample)
+	}
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode dump: %w", err)
+	}
+	if err := ioutil.WriteFile(filePath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write dump: %w", err)
+	}
+	return nil
+}
+
+// runDiagnostics dumps the encoded validation inputs, to a JSON file when
+// dumpPath is set and to stderr otherwise, and compares them with the
+// reference encodings exported by the Python side, if any
+func runDiagnostics(torchData *TorchModelData, batch *EncodedBatch, dumpPath string) error {
+	fmt.Printf("\n=== Encoding Diagnostics ===\n")
+	if dumpPath != "" {
+		if err := writeEncodedDump(dumpPath, batch, torchData.ValidationData); err != nil {
+			return err
+		}
+		fmt.Printf("Encoded tensors written to %s\n", dumpPath)
+	} else {
+		printEncodedDump(os.Stderr, batch, torchData.ValidationData, torchData.ValidationEncodedInputs)
+		fmt.Printf("Encoded tensors of %d samples written to stderr\n", batch.NumSamples)
+	}
+
+	if torchData.ValidationEncodedInputs == nil {
+		fmt.Printf("Artifact has no validation_encoded_inputs; export them from Python to compare encodings\n")
+		fmt.Printf("Expected layout: {\"numerical\": [[...], ...], \"categorical\": [[...], ...]} in feature order:\n")
+		fmt.Printf("  numerical: %s\n  categorical: %s\n", strings.Join(batch.NumericalFeatures, ", "), strings.Join(batch.CategoricalFeatures, ", "))
+		return nil
+	}
+
+	divergences, err := compareEncodings(batch, torchData.ValidationEncodedInputs, torchData.ValidationData)
+	if err != nil {
+		return err
+	}
+	printDivergenceSummary(divergences)
+	return nil
+}
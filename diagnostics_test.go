@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNumericalEncodingDiverges(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	tests := []struct {
+		got      float64
+		want     float64
+		diverges bool
+	}{
+		{1, 1, false},
+		{1 + 1e-7, 1, false},
+		{1.01, 1, true},
+		{1000.0001, 1000, false},
+		{nan, 1, true},
+		{1, nan, true},
+		{nan, nan, false},
+		{inf, 1e308, true},
+		{inf, -inf, true},
+		{inf, inf, false},
+		{0, inf, true},
+	}
+	for _, tc := range tests {
+		if got := numericalEncodingDiverges(tc.got, tc.want); got != tc.diverges {
+			t.Errorf("numericalEncodingDiverges(%g, %g) = %t, want %t", tc.got, tc.want, got, tc.diverges)
+		}
+	}
+}
+
+func TestCompareEncodingsFlagsNonFinite(t *testing.T) {
+	batch := &EncodedBatch{
+		NumSamples:        2,
+		NumericalFeatures: []string{"price", "age"},
+		Numerical:         []float32{float32(math.NaN()), 3, 2, float32(math.Inf(1))},
+	}
+	reference := &EncodedInputs{Numerical: [][]float64{{1, 3}, {2, 4}}}
+	samples := []ValidationData{{"price": "NaN", "age": 3}, {"price": 2, "age": "Inf"}}
+
+	divergences, err := compareEncodings(batch, reference, samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(divergences) != 2 {
+		t.Fatalf("got %d divergences, want 2: %+v", len(divergences), divergences)
+	}
+	if d := divergences[0]; d.Sample != 0 || d.Feature != "price" {
+		t.Errorf("first divergence = sample %d %s, want sample 0 price", d.Sample, d.Feature)
+	}
+	if d := divergences[1]; d.Sample != 1 || d.Feature != "age" {
+		t.Errorf("second divergence = sample %d %s, want sample 1 age", d.Sample, d.Feature)
+	}
+}
+
+func TestWriteEncodedDumpNonFinite(t *testing.T) {
+	batch := &EncodedBatch{
+		NumSamples:          1,
+		NumericalFeatures:   []string{"price", "age", "bid"},
+		CategoricalFeatures: []string{"geo"},
+		Numerical:           []float32{float32(math.NaN()), float32(math.Inf(-1)), 0.5},
+		Categorical:         []int64{2},
+	}
+	path := filepath.Join(t.TempDir(), "dump.json")
+	if err := writeEncodedDump(path, batch, []ValidationData{{"price": "NaN", "age": "-Inf", "bid": 0.5, "geo": "US"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump struct {
+		Samples []struct {
+			Numerical []*float64 `json:"numerical"`
+		} `json:"samples"`
+	}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatal(err)
+	}
+	numerical := dump.Samples[0].Numerical
+	if len(numerical) != 3 || numerical[0] != nil || numerical[1] != nil || numerical[2] == nil || *numerical[2] != 0.5 {
+		t.Errorf("numerical = %s, want [null, null, 0.5]", data)
+	}
+}
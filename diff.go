@@ -0,0 +1,621 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FieldChange is a value compared between two artifacts. Old is empty for
+// facts about the comparison itself.
+type FieldChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+// FeatureChange is a feature added, removed or changed between two artifacts
+type FeatureChange struct {
+	Name string
+	// Kind is "+" for added, "-" for removed and "~" for changed features
+	Kind   string
+	Detail string
+}
+
+// VocabularyChange describes how the label encoder of one categorical
+// feature present in both artifacts changed
+type VocabularyChange struct {
+	Feature string
+	OldSize int
+	NewSize int
+	Added   []string
+	Removed []string
+	// Moved counts classes kept at a different index, which feeds them a
+	// different embedding row
+	Moved int
+}
+
+// DeltaDistribution summarizes per-output prediction deltas, new minus old
+type DeltaDistribution struct {
+	Count   int
+	Mean    float64
+	Std     float64
+	MeanAbs float64
+	// Quantiles holds the delta at each of deltaQuantiles
+	Quantiles []float64
+	// AbsQuantiles holds the absolute delta at each of deltaQuantiles
+	AbsQuantiles []float64
+	// Unchanged counts outputs whose absolute delta is within the tolerance
+	Unchanged int
+	// NonFinite counts outputs whose delta is NaN or infinite. The other
+	// statistics cover the finite deltas only.
+	NonFinite int
+	Tolerance float64
+}
+
+// deltaQuantiles are the quantiles reported for prediction deltas
+var deltaQuantiles = []float64{0, 0.05, 0.25, 0.5, 0.75, 0.95, 1}
+
+// runDiff compares two artifacts: features, vocabularies, hyperparameters,
+// training curves and, unless disabled, the predictions of both models on
+// the union of their validation data
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	oldPath := flags.String("old", "", "path to the old model JSON file or model directory")
+	newPath := flags.String("new", "", "path to the new model JSON file or model directory")
+	load := addLoadFlags(flags)
+	encoding := addEncodingFlags(flags)
+	examples := flags.Int("examples", 5, "number of added or removed classes to list per vocabulary")
+	worst := flags.Int("worst", 5, "number of samples with the largest prediction deltas to report")
+	batchSize := flags.Int("batch-size", 1024, "number of samples per forward pass")
+	predict := flags.Bool("predict", true, "score both models on the union of their validation data")
+	flags.Parse(args)
+
+	if *oldPath == "" || *newPath == "" {
+		log.Fatalf("Invalid flags: -old and -new are required")
+	}
+	options, err := load.options()
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	oldModelData, oldData, err := loadArtifact(*oldPath, options)
+	if err != nil {
+		log.Fatalf("Failed to load %s: %v", *oldPath, err)
+	}
+	newModelData, newData, err := loadArtifact(*newPath, options)
+	if err != nil {
+		log.Fatalf("Failed to load %s: %v", *newPath, err)
+	}
+
+	fmt.Printf("=== Artifact Diff ===\n")
+	fmt.Printf("Old: %s (interval %d)\n", *oldPath, oldModelData.Interval)
+	fmt.Printf("New: %s (interval %d)\n", *newPath, newModelData.Interval)
+
+	fmt.Printf("\nFeatures:\n")
+	featureChanges := diffFeatures(oldData.FeatureInfo, newData.FeatureInfo)
+	for _, change := range featureChanges {
+		if change.Detail == "" {
+			fmt.Printf("%s %s\n", change.Kind, change.Name)
+		} else {
+			fmt.Printf("%s %s: %s\n", change.Kind, change.Name, change.Detail)
+		}
+	}
+	if len(featureChanges) == 0 {
+		fmt.Printf("  no changes\n")
+	}
+
+	fmt.Printf("\nVocabularies:\n")
+	vocabularyChanges := diffVocabularies(oldData.FeatureInfo, newData.FeatureInfo)
+	for _, change := range vocabularyChanges {
+		fmt.Printf("- %s: %d -> %d classes, +%d -%d", change.Feature, change.OldSize, change.NewSize, len(change.Added), len(change.Removed))
+		if change.Moved > 0 {
+			fmt.Printf(", %d moved to another index", change.Moved)
+		}
+		fmt.Printf("\n")
+		if len(change.Added) > 0 {
+			fmt.Printf("    added: %s\n", quoteExamples(change.Added, *examples))
+		}
+		if len(change.Removed) > 0 {
+			fmt.Printf("    removed: %s\n", quoteExamples(change.Removed, *examples))
+		}
+	}
+	if len(vocabularyChanges) == 0 {
+		fmt.Printf("  no changes\n")
+	}
+
+	fmt.Printf("\nHyperparameters:\n")
+	printFieldChanges(diffHyperparameters(oldData, newData))
+
+	fmt.Printf("\nTraining:\n")
+	printFieldChanges(diffTraining(oldData.TrainingHistory, newData.TrainingHistory))
+
+	if !*predict {
+		return
+	}
+	samples, shared := unionSamples(oldData.ValidationData, newData.ValidationData)
+	fmt.Printf("\n=== Prediction Deltas ===\n")
+	fmt.Printf("Samples: %d (%d old, %d new, %d shared)\n", len(samples), len(oldData.ValidationData), len(newData.ValidationData), shared)
+	if len(samples) == 0 {
+		fmt.Printf("Neither artifact has validation data\n")
+		return
+	}
+
+	oldPredictions, err := diffPredictions("old", oldData, samples, options, encoding, *batchSize)
+	if err != nil {
+		log.Fatalf("Failed to score the old model: %v", err)
+	}
+	newPredictions, err := diffPredictions("new", newData, samples, options, encoding, *batchSize)
+	if err != nil {
+		log.Fatalf("Failed to score the new model: %v", err)
+	}
+	if len(oldPredictions) != len(newPredictions) {
+		log.Fatalf("The models produce %d and %d outputs for %d samples; their predictions cannot be compared", len(oldPredictions), len(newPredictions), len(samples))
+	}
+
+	tolerance := math.Max(oldData.ValidationTolerance, newData.ValidationTolerance)
+	distribution := deltaDistribution(oldPredictions, newPredictions, tolerance)
+	printDeltaDistribution(distribution)
+
+	if *worst > 0 {
+		outputsPerSample := len(oldPredictions) / len(samples)
+		fmt.Printf("\nLargest deltas:\n")
+		for _, index := range largestDeltas(oldPredictions, newPredictions, *worst) {
+			fmt.Printf("- sample %d", index/outputsPerSample+1)
+			if outputsPerSample > 1 {
+				fmt.Printf(" class %d", index%outputsPerSample)
+			}
+			fmt.Printf(": old %.6f, new %.6f, delta %+.6g\n", oldPredictions[index], newPredictions[index], newPredictions[index]-oldPredictions[index])
+		}
+	}
+}
+
+// diffPredictions scores samples with one artifact's model. Features the
+// artifact uses but a sample lacks, e.g. features added in the other
+// version, get the artifact's declared missing values.
+func diffPredictions(label string, torchData *TorchModelData, samples []ValidationData, loadOptions LoadOptions, encoding *encodingFlags, batchSize int) ([]float64, error) {
+	completed, filled := fillMissingFeatures(samples, torchData.FeatureInfo)
+	if filled > 0 {
+		fmt.Printf("Filled missing features with the %s artifact's missing values in %d samples\n", label, filled)
+	}
+
+	model, err := loadTorchModel(torchData, loadOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer model.Free()
+	options, err := encoding.options(torchData.FeatureInfo)
+	if err != nil {
+		return nil, err
+	}
+	return predictSamples(model, completed, torchData.FeatureInfo, options, batchSize)
+}
+
+// diffFeatures lists features added, removed or changed in kind or
+// transforms, in the new artifact's order followed by removed features
+func diffFeatures(oldInfo FeatureInfo, newInfo FeatureInfo) []FeatureChange {
+	oldKinds, newKinds := featureKinds(oldInfo), featureKinds(newInfo)
+	var changes []FeatureChange
+	for _, kind := range []string{"numerical", "categorical"} {
+		for _, featureName := range newInfo.FeatureNames[kind] {
+			oldKind, exists := oldKinds[featureName]
+			switch {
+			case !exists:
+				changes = append(changes, FeatureChange{Name: featureName, Kind: "+", Detail: kind})
+			case oldKind != kind:
+				changes = append(changes, FeatureChange{Name: featureName, Kind: "~", Detail: oldKind + " -> " + kind})
+			case kind == "numerical":
+				oldSteps, newSteps := transformSummary(oldInfo.Transforms[featureName]), transformSummary(newInfo.Transforms[featureName])
+				if oldSteps != newSteps {
+					changes = append(changes, FeatureChange{Name: featureName, Kind: "~", Detail: "transforms " + oldSteps + " -> " + newSteps})
+				}
+			}
+		}
+	}
+	for _, kind := range []string{"numerical", "categorical"} {
+		for _, featureName := range oldInfo.FeatureNames[kind] {
+			if _, exists := newKinds[featureName]; !exists {
+				changes = append(changes, FeatureChange{Name: featureName, Kind: "-", Detail: kind})
+			}
+		}
+	}
+
+	oldOrder := append(append([]string{}, oldInfo.FeatureNames["numerical"]...), oldInfo.FeatureNames["categorical"]...)
+	newOrder := append(append([]string{}, newInfo.FeatureNames["numerical"]...), newInfo.FeatureNames["categorical"]...)
+	if len(changes) == 0 && strings.Join(oldOrder, "\x00") != strings.Join(newOrder, "\x00") {
+		changes = append(changes, FeatureChange{Name: "feature order", Kind: "~", Detail: strings.Join(oldOrder, ", ") + " -> " + strings.Join(newOrder, ", ")})
+	}
+	return changes
+}
+
+// featureKinds maps every feature to numerical or categorical
+func featureKinds(featureInfo FeatureInfo) map[string]string {
+	kinds := make(map[string]string)
+	for _, kind := range []string{"numerical", "categorical"} {
+		for _, featureName := range featureInfo.FeatureNames[kind] {
+			kinds[featureName] = kind
+		}
+	}
+	return kinds
+}
+
+// transformSummary describes a transform chain with its parameters
+func transformSummary(transforms []FeatureTransform) string {
+	if len(transforms) == 0 {
+		return "none"
+	}
+	data, err := json.Marshal(transforms)
+	if err != nil {
+		return "?"
+	}
+	return string(data)
+}
+
+// diffVocabularies compares the label encoders of the categorical features
+// present in both artifacts and returns those that changed
+func diffVocabularies(oldInfo FeatureInfo, newInfo FeatureInfo) []VocabularyChange {
+	oldKinds := featureKinds(oldInfo)
+	var changes []VocabularyChange
+	for _, featureName := range newInfo.FeatureNames["categorical"] {
+		if oldKinds[featureName] != "categorical" {
+			continue
+		}
+		oldClasses := oldInfo.MissingValueHandling.LabelEncoders[featureName].Classes
+		newClasses := newInfo.MissingValueHandling.LabelEncoders[featureName].Classes
+		change := VocabularyChange{Feature: featureName, OldSize: len(oldClasses), NewSize: len(newClasses)}
+
+		oldIndex := make(map[string]int, len(oldClasses))
+		for i, class := range oldClasses {
+			oldIndex[class] = i
+		}
+		newIndex := make(map[string]int, len(newClasses))
+		for i, class := range newClasses {
+			newIndex[class] = i
+			previous, exists := oldIndex[class]
+			switch {
+			case !exists:
+				change.Added = append(change.Added, class)
+			case previous != i:
+				change.Moved++
+			}
+		}
+		for _, class := range oldClasses {
+			if _, exists := newIndex[class]; !exists {
+				change.Removed = append(change.Removed, class)
+			}
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 || change.Moved > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// quoteExamples quotes up to limit values, noting how many were left out
+func quoteExamples(values []string, limit int) string {
+	quoted := make([]string, 0, limit)
+	for i, value := range values {
+		if i == limit {
+			break
+		}
+		quoted = append(quoted, fmt.Sprintf("%q", value))
+	}
+	if len(values) > limit {
+		return fmt.Sprintf("%s (and %d more)", strings.Join(quoted, ", "), len(values)-limit)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// diffHyperparameters compares the top-level hyperparameters and the
+// architecture in torch_model.config, including keys this tool does not
+// interpret
+func diffHyperparameters(oldData *TorchModelData, newData *TorchModelData) []FieldChange {
+	var changes []FieldChange
+	compare := func(name string, oldValue interface{}, newValue interface{}) {
+		oldText, newText := fmt.Sprint(oldValue), fmt.Sprint(newValue)
+		if oldText != newText {
+			changes = append(changes, FieldChange{Name: name, Old: oldText, New: newText})
+		}
+	}
+	compare("Task Type", oldData.TaskType, newData.TaskType)
+	compare("Learning Rate", oldData.LearningRate, newData.LearningRate)
+	compare("Weight Decay", oldData.WeightDecay, newData.WeightDecay)
+	compare("Epochs", oldData.Epochs, newData.Epochs)
+	compare("Batch Size", oldData.BatchSize, newData.BatchSize)
+	compare("Weight Column", oldData.WeightColumn, newData.WeightColumn)
+	compare("Target Column", oldData.FeatureInfo.TargetColumn, newData.FeatureInfo.TargetColumn)
+	compare("Validation Tolerance", oldData.ValidationTolerance, newData.ValidationTolerance)
+
+	oldConfig, oldErr := parseModelConfig(oldData.TorchModel.Config)
+	newConfig, newErr := parseModelConfig(newData.TorchModel.Config)
+	if oldErr != nil || newErr != nil {
+		compare("torch_model.config", oldData.TorchModel.Config, newData.TorchModel.Config)
+		return changes
+	}
+	keys := make(map[string]bool)
+	for key := range oldConfig.fields {
+		keys[key] = true
+	}
+	for key := range newConfig.fields {
+		keys[key] = true
+	}
+	sortedConfigKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedConfigKeys = append(sortedConfigKeys, key)
+	}
+	sort.Strings(sortedConfigKeys)
+	for _, key := range sortedConfigKeys {
+		compare("config."+key, configValue(oldConfig.fields, key), configValue(newConfig.fields, key))
+	}
+	return changes
+}
+
+// configValue returns a config value in compact JSON, or "unset"
+func configValue(fields map[string]json.RawMessage, key string) string {
+	raw, exists := fields[key]
+	if !exists {
+		return "unset"
+	}
+	var value interface{}
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return string(raw)
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+// diffTraining compares the loss curves and training times; identical
+// histories have no changes
+func diffTraining(oldHistory TrainingHistory, newHistory TrainingHistory) []FieldChange {
+	oldLosses, newLosses := oldHistory.TrainLosses, newHistory.TrainLosses
+	if sameLosses(oldLosses, newLosses) && oldHistory.TotalTrainingTime == newHistory.TotalTrainingTime {
+		return nil
+	}
+	changes := []FieldChange{
+		{Name: "Epochs Trained", Old: fmt.Sprint(len(oldLosses)), New: fmt.Sprint(len(newLosses))},
+	}
+	if len(oldLosses) > 0 && len(newLosses) > 0 {
+		oldBest, newBest := minLoss(oldLosses), minLoss(newLosses)
+		changes = append(changes,
+			lossChange("First Loss", oldLosses[0], newLosses[0]),
+			lossChange("Best Loss", oldBest, newBest),
+			lossChange("Final Loss", oldLosses[len(oldLosses)-1], newLosses[len(newLosses)-1]),
+		)
+
+		common := len(oldLosses)
+		if len(newLosses) < common {
+			common = len(newLosses)
+		}
+		lower, largest, largestEpoch := 0, 0.0, 0
+		for epoch := 0; epoch < common; epoch++ {
+			if sameLosses(oldLosses[epoch:epoch+1], newLosses[epoch:epoch+1]) {
+				continue
+			}
+			delta := newLosses[epoch] - oldLosses[epoch]
+			if delta < 0 {
+				lower++
+			}
+			if largestEpoch == 0 || largerDistance(math.Abs(delta), math.Abs(largest)) {
+				largest, largestEpoch = delta, epoch+1
+			}
+		}
+		changes = append(changes, FieldChange{Name: "Epochs With Lower Loss", New: fmt.Sprintf("%d of %d common epochs", lower, common)})
+		if largestEpoch > 0 {
+			changes = append(changes, FieldChange{Name: "Largest Loss Delta", New: fmt.Sprintf("%+.6g at epoch %d", largest, largestEpoch)})
+		}
+	}
+	changes = append(changes, FieldChange{Name: "Total Training Time", Old: formatDuration(oldHistory.TotalTrainingTime), New: formatDuration(newHistory.TotalTrainingTime)})
+	return changes
+}
+
+// sameLosses reports whether two loss curves are equal, NaN matching NaN
+func sameLosses(oldLosses []float64, newLosses []float64) bool {
+	if len(oldLosses) != len(newLosses) {
+		return false
+	}
+	for i := range oldLosses {
+		if oldLosses[i] != newLosses[i] && !(math.IsNaN(oldLosses[i]) && math.IsNaN(newLosses[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// lossChange describes a loss difference with its relative change
+func lossChange(name string, oldLoss float64, newLoss float64) FieldChange {
+	change := FieldChange{Name: name, Old: fmt.Sprintf("%.6g", oldLoss), New: fmt.Sprintf("%.6g", newLoss)}
+	if oldLoss != 0 {
+		change.New += fmt.Sprintf(" (%+.2f%%)", (newLoss-oldLoss)/math.Abs(oldLoss)*100)
+	}
+	return change
+}
+
+// minLoss returns the smallest loss
+func minLoss(losses []float64) float64 {
+	best := losses[0]
+	for _, loss := range losses[1:] {
+		best = math.Min(best, loss)
+	}
+	return best
+}
+
+// printFieldChanges prints changed values as old -> new; changes without an
+// old value are printed as facts
+func printFieldChanges(changes []FieldChange) {
+	if len(changes) == 0 {
+		fmt.Printf("  no changes\n")
+	}
+	for _, change := range changes {
+		if change.Old == "" {
+			fmt.Printf("- %s: %s\n", change.Name, change.New)
+		} else {
+			fmt.Printf("- %s: %s -> %s\n", change.Name, change.Old, change.New)
+		}
+	}
+}
+
+// unionSamples merges two sets of validation samples, dropping duplicates
+// and samples of the second set identical to one of the first, and returns
+// the number of distinct samples the sets share
+func unionSamples(oldSamples []ValidationData, newSamples []ValidationData) ([]ValidationData, int) {
+	oldKeys := make(map[string]bool, len(oldSamples))
+	union := make([]ValidationData, 0, len(oldSamples)+len(newSamples))
+	for _, sample := range oldSamples {
+		key, _ := json.Marshal(sample)
+		if !oldKeys[string(key)] {
+			oldKeys[string(key)] = true
+			union = append(union, sample)
+		}
+	}
+	newKeys := make(map[string]bool, len(newSamples))
+	shared := 0
+	for _, sample := range newSamples {
+		key, _ := json.Marshal(sample)
+		if newKeys[string(key)] {
+			continue
+		}
+		newKeys[string(key)] = true
+		if oldKeys[string(key)] {
+			shared++
+			continue
+		}
+		union = append(union, sample)
+	}
+	return union, shared
+}
+
+// fillMissingFeatures returns the samples with every feature of featureInfo
+// present, filling absent ones with its declared missing values, and the
+// number of samples that needed filling. Complete samples are not copied.
+func fillMissingFeatures(samples []ValidationData, featureInfo FeatureInfo) ([]ValidationData, int) {
+	missingValues := make(map[string]interface{})
+	for _, featureName := range featureInfo.FeatureNames["numerical"] {
+		missingValues[featureName] = featureInfo.MissingValueHandling.NumericalMissingValue
+	}
+	for _, featureName := range featureInfo.FeatureNames["categorical"] {
+		missingValues[featureName] = featureInfo.MissingValueHandling.CategoricalMissingValue
+	}
+
+	completed := make([]ValidationData, len(samples))
+	filled := 0
+	for i, sample := range samples {
+		completed[i] = sample
+		var missing []string
+		for featureName := range missingValues {
+			if _, exists := sample[featureName]; !exists {
+				missing = append(missing, featureName)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		copied := make(ValidationData, len(sample)+len(missing))
+		for key, value := range sample {
+			copied[key] = value
+		}
+		for _, featureName := range missing {
+			copied[featureName] = missingValues[featureName]
+		}
+		completed[i] = copied
+		filled++
+	}
+	return completed, filled
+}
+
+// deltaDistribution summarizes the deltas between two sets of outputs
+func deltaDistribution(oldPredictions []float64, newPredictions []float64, tolerance float64) DeltaDistribution {
+	distribution := DeltaDistribution{Count: len(oldPredictions), Tolerance: tolerance}
+	var deltas, absDeltas []float64
+	var sum, sumAbs float64
+	for i := range oldPredictions {
+		delta := newPredictions[i] - oldPredictions[i]
+		if math.IsNaN(delta) || math.IsInf(delta, 0) {
+			distribution.NonFinite++
+			continue
+		}
+		deltas = append(deltas, delta)
+		absDeltas = append(absDeltas, math.Abs(delta))
+		sum += delta
+		sumAbs += math.Abs(delta)
+		if math.Abs(delta) <= tolerance {
+			distribution.Unchanged++
+		}
+	}
+	if len(deltas) == 0 {
+		return distribution
+	}
+	count := float64(len(deltas))
+	distribution.Mean = sum / count
+	distribution.MeanAbs = sumAbs / count
+	var squares float64
+	for _, delta := range deltas {
+		squares += (delta - distribution.Mean) * (delta - distribution.Mean)
+	}
+	distribution.Std = math.Sqrt(squares / count)
+
+	sort.Float64s(deltas)
+	sort.Float64s(absDeltas)
+	for _, q := range deltaQuantiles {
+		distribution.Quantiles = append(distribution.Quantiles, quantile(deltas, q))
+		distribution.AbsQuantiles = append(distribution.AbsQuantiles, quantile(absDeltas, q))
+	}
+	return distribution
+}
+
+// quantile returns the q-quantile of sorted values, interpolating linearly
+func quantile(sorted []float64, q float64) float64 {
+	position := q * float64(len(sorted)-1)
+	lower := int(math.Floor(position))
+	if lower >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	fraction := position - float64(lower)
+	return sorted[lower] + fraction*(sorted[lower+1]-sorted[lower])
+}
+
+// printDeltaDistribution prints the delta statistics and quantiles
+func printDeltaDistribution(distribution DeltaDistribution) {
+	fmt.Printf("Outputs compared: %d\n", distribution.Count)
+	if distribution.Count == 0 {
+		return
+	}
+	fmt.Printf("Unchanged (|delta| <= %g): %d/%d (%.1f%%)\n", distribution.Tolerance, distribution.Unchanged, distribution.Count, float64(distribution.Unchanged)/float64(distribution.Count)*100)
+	if distribution.NonFinite > 0 {
+		fmt.Printf("Non-finite deltas (NaN or infinite): %d/%d, excluded from the statistics below\n", distribution.NonFinite, distribution.Count)
+	}
+	if distribution.NonFinite == distribution.Count {
+		return
+	}
+	fmt.Printf("Mean delta: %+.6g, std %.6g, mean |delta| %.6g\n", distribution.Mean, distribution.Std, distribution.MeanAbs)
+	fmt.Printf("\n%-10s %14s %14s\n", "Quantile", "Delta", "|Delta|")
+	for i, q := range deltaQuantiles {
+		label := fmt.Sprintf("p%g", q*100)
+		switch q {
+		case 0:
+			label = "min"
+		case 1:
+			label = "max"
+		}
+		fmt.Printf("%-10s %+14.6g %14.6g\n", label, distribution.Quantiles[i], distribution.AbsQuantiles[i])
+	}
+}
+
+// largestDeltas returns the output indices with the largest absolute
+// deltas, largest first and NaN deltas before any number
+func largestDeltas(oldPredictions []float64, newPredictions []float64, n int) []int {
+	indices := make([]int, len(oldPredictions))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(a, b int) bool {
+		return largerDistance(math.Abs(newPredictions[indices[a]]-oldPredictions[indices[a]]), math.Abs(newPredictions[indices[b]]-oldPredictions[indices[b]]))
+	})
+	if len(indices) > n {
+		indices = indices[:n]
+	}
+	return indices
+}
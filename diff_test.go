@@ -0,0 +1,162 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestDiffFeatures(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	oldInfo := FeatureInfo{
+		FeatureNames: map[string][]string{"numerical": {"price", "age"}, "categorical": {"geo", "os"}},
+		Transforms:   map[string][]FeatureTransform{"price": {{Type: TransformLog1p}}},
+	}
+	newInfo := FeatureInfo{
+		FeatureNames: map[string][]string{"numerical": {"price", "os"}, "categorical": {"geo", "zone"}},
+		Transforms:   map[string][]FeatureTransform{"price": {{Type: TransformClip, Min: value(0)}}},
+	}
+	want := []FeatureChange{
+		{Name: "price", Kind: "~", Detail: `transforms [{"type":"log1p"}] -> [{"type":"clip","min":0}]`},
+		{Name: "os", Kind: "~", Detail: "categorical -> numerical"},
+		{Name: "zone", Kind: "+", Detail: "categorical"},
+		{Name: "age", Kind: "-", Detail: "numerical"},
+	}
+	if got := diffFeatures(oldInfo, newInfo); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffFeatures = %+v, want %+v", got, want)
+	}
+
+	reordered := FeatureInfo{FeatureNames: map[string][]string{"numerical": {"age", "price"}, "categorical": {"geo", "os"}}, Transforms: oldInfo.Transforms}
+	got := diffFeatures(oldInfo, reordered)
+	if len(got) != 1 || got[0].Name != "feature order" {
+		t.Errorf("reordered features: got %+v, want a feature order change", got)
+	}
+	if got := diffFeatures(oldInfo, oldInfo); len(got) != 0 {
+		t.Errorf("identical features: got %+v, want no changes", got)
+	}
+}
+
+func TestDiffVocabularies(t *testing.T) {
+	encoders := func(classes map[string][]string) MissingValueHandling {
+		handling := MissingValueHandling{LabelEncoders: make(map[string]LabelEncoder)}
+		for featureName, featureClasses := range classes {
+			handling.LabelEncoders[featureName] = LabelEncoder{Classes: featureClasses}
+		}
+		return handling
+	}
+	oldInfo := FeatureInfo{
+		FeatureNames:         map[string][]string{"categorical": {"geo", "os", "zone"}},
+		MissingValueHandling: encoders(map[string][]string{"geo": {"DE", "FR", "US"}, "os": {"android", "iOS"}, "zone": {"a"}}),
+	}
+	newInfo := FeatureInfo{
+		FeatureNames:         map[string][]string{"numerical": {"zone"}, "categorical": {"geo", "os", "app"}},
+		MissingValueHandling: encoders(map[string][]string{"geo": {"DE", "GB", "US"}, "os": {"iOS", "android"}, "app": {"x"}}),
+	}
+	want := []VocabularyChange{
+		{Feature: "geo", OldSize: 3, NewSize: 3, Added: []string{"GB"}, Removed: []string{"FR"}},
+		{Feature: "os", OldSize: 2, NewSize: 2, Moved: 2},
+	}
+	if got := diffVocabularies(oldInfo, newInfo); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffVocabularies = %+v, want %+v", got, want)
+	}
+	if got := diffVocabularies(oldInfo, oldInfo); len(got) != 0 {
+		t.Errorf("identical vocabularies: got %+v, want no changes", got)
+	}
+}
+
+func TestUnionSamples(t *testing.T) {
+	oldSamples := []ValidationData{{"geo": "US"}, {"geo": "DE"}, {"geo": "US"}}
+	newSamples := []ValidationData{{"geo": "DE"}, {"geo": "FR"}, {"geo": "FR"}}
+	union, shared := unionSamples(oldSamples, newSamples)
+	want := []ValidationData{{"geo": "US"}, {"geo": "DE"}, {"geo": "FR"}}
+	if !reflect.DeepEqual(union, want) {
+		t.Errorf("union = %v, want %v", union, want)
+	}
+	// The repeated FR sample is a duplicate within the new set, not a shared one
+	if shared != 1 {
+		t.Errorf("shared = %d, want 1", shared)
+	}
+}
+
+func TestFillMissingFeatures(t *testing.T) {
+	featureInfo := FeatureInfo{
+		FeatureNames: map[string][]string{"numerical": {"price"}, "categorical": {"geo"}},
+		MissingValueHandling: MissingValueHandling{
+			NumericalMissingValue:   -1.0,
+			CategoricalMissingValue: "unknown",
+		},
+	}
+	complete := ValidationData{"price": 2.0, "geo": "US"}
+	partial := ValidationData{"geo": "DE", "extra": true}
+	samples := []ValidationData{complete, partial, {}}
+
+	completed, filled := fillMissingFeatures(samples, featureInfo)
+	if filled != 2 {
+		t.Errorf("filled = %d, want 2", filled)
+	}
+	want := []ValidationData{
+		complete,
+		{"price": -1.0, "geo": "DE", "extra": true},
+		{"price": -1.0, "geo": "unknown"},
+	}
+	if !reflect.DeepEqual(completed, want) {
+		t.Errorf("completed = %v, want %v", completed, want)
+	}
+	if _, exists := partial["price"]; exists {
+		t.Errorf("fillMissingFeatures modified its input")
+	}
+}
+
+func TestDeltaDistribution(t *testing.T) {
+	oldPredictions := []float64{1, 2, 3, 4, 5}
+	newPredictions := []float64{1, 2.5, 2, 4.001, 7}
+	distribution := deltaDistribution(oldPredictions, newPredictions, 0.01)
+	// Deltas 0, 0.5, -1, 0.001, 2
+	if distribution.Count != 5 || distribution.Unchanged != 2 || distribution.NonFinite != 0 {
+		t.Errorf("counts = %d, %d unchanged, %d non-finite, want 5, 2, 0", distribution.Count, distribution.Unchanged, distribution.NonFinite)
+	}
+	mean := (0 + 0.5 - 1 + 0.001 + 2) / 5
+	if !closeTo(distribution.Mean, mean) || !closeTo(distribution.MeanAbs, 3.501/5) {
+		t.Errorf("mean = %g, mean abs = %g, want %g, %g", distribution.Mean, distribution.MeanAbs, mean, 3.501/5)
+	}
+	if !closeTo(distribution.Quantiles[0], -1) || !closeTo(distribution.Quantiles[len(deltaQuantiles)-1], 2) {
+		t.Errorf("quantiles = %v, want min -1 and max 2", distribution.Quantiles)
+	}
+	if !closeTo(distribution.AbsQuantiles[0], 0) || !closeTo(distribution.AbsQuantiles[len(deltaQuantiles)-1], 2) {
+		t.Errorf("absolute quantiles = %v, want min 0 and max 2", distribution.AbsQuantiles)
+	}
+}
+
+func TestDeltaDistributionNonFinite(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	oldPredictions := []float64{1, nan, 3, inf, 5, 6}
+	newPredictions := []float64{2, 1, nan, inf, inf, 6}
+	distribution := deltaDistribution(oldPredictions, newPredictions, 0)
+	if distribution.Count != 6 || distribution.NonFinite != 4 || distribution.Unchanged != 1 {
+		t.Errorf("counts = %d, %d non-finite, %d unchanged, want 6, 4, 1", distribution.Count, distribution.NonFinite, distribution.Unchanged)
+	}
+	// Statistics cover the finite deltas 1 and 0 only
+	for name, got := range map[string]float64{"mean": distribution.Mean, "std": distribution.Std, "mean abs": distribution.MeanAbs} {
+		if !closeTo(got, 0.5) {
+			t.Errorf("%s = %g, want 0.5", name, got)
+		}
+	}
+	for i, q := range deltaQuantiles {
+		if got := distribution.Quantiles[i]; math.IsNaN(got) || !closeTo(got, q) {
+			t.Errorf("quantile %g = %g, want %g", q, got, q)
+		}
+	}
+
+	allNonFinite := deltaDistribution([]float64{nan}, []float64{1}, 0)
+	if allNonFinite.NonFinite != 1 || allNonFinite.Quantiles != nil || allNonFinite.Mean != 0 {
+		t.Errorf("all non-finite: got %+v, want only the non-finite count", allNonFinite)
+	}
+}
+
+func TestLargestDeltasRanksNaNFirst(t *testing.T) {
+	oldPredictions := []float64{1, 1, 1, 1}
+	newPredictions := []float64{1.5, 3, math.NaN(), 0}
+	if got, want := largestDeltas(oldPredictions, newPredictions, 3), []int{2, 1, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("largestDeltas = %v, want %v", got, want)
+	}
+}
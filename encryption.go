@@ -0,0 +1,355 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// EncryptionAES256GCM is the only supported artifact encryption algorithm
+const EncryptionAES256GCM = "aes-256-gcm"
+
+// encryptionDomain prefixes the associated data of every ciphertext
+const encryptionDomain = "go-torch-demo artifact encryption v1\n"
+
+// Names of the encrypted fields, bound into their ciphertexts' associated data
+const (
+	modelEncryptedField       = "torch_model.model"
+	featureInfoEncryptedField = "feature_info"
+)
+
+// ArtifactEncryption describes which parts of an artifact are encrypted and
+// with which key. Ciphertexts are base64 of the GCM nonce followed by the
+// sealed data.
+type ArtifactEncryption struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id"`
+	// Model means TorchModel.Model holds the encrypted (and then, if
+	// compressed, compressed first) payload
+	Model bool `json:"model"`
+	// FeatureInfo means feature_info is replaced by encrypted_feature_info
+	FeatureInfo bool `json:"feature_info"`
+}
+
+// KeyProvider supplies AES-256 keys by key ID
+type KeyProvider interface {
+	Key(keyID string) ([]byte, error)
+}
+
+// keyfileProvider serves keys from a local JSON file mapping key IDs to
+// base64 32-byte keys
+type keyfileProvider struct {
+	path string
+	keys map[string][]byte
+}
+
+// Key returns the key with the given ID
+func (p *keyfileProvider) Key(keyID string) ([]byte, error) {
+	key, exists := p.keys[keyID]
+	if !exists {
+		return nil, fmt.Errorf("key %q not found in %s", keyID, p.path)
+	}
+	return key, nil
+}
+
+// envKeyProvider serves base64 keys from environment variables named by a
+// prefix and the upper-cased key ID, e.g. MODEL_KEY_PARTNER_A
+type envKeyProvider struct {
+	prefix string
+}
+
+// Key returns the key with the given ID
+func (p *envKeyProvider) Key(keyID string) ([]byte, error) {
+	name := p.prefix + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, keyID)
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return decodeAESKey(value)
+}
+
+// newKeyProvider creates a key provider from a spec: "env:PREFIX" reads
+// keys from environment variables, "file:PATH" or a plain path from a keyfile
+func newKeyProvider(spec string) (KeyProvider, error) {
+	if prefix, found := strings.CutPrefix(spec, "env:"); found {
+		return &envKeyProvider{prefix: prefix}, nil
+	}
+	return loadKeyfile(strings.TrimPrefix(spec, "file:"))
+}
+
+// loadKeyfile reads a keyfile for keyfileProvider
+func loadKeyfile(filePath string) (*keyfileProvider, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyfile: %w", err)
+	}
+	var encoded map[string]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("failed to parse keyfile: %w", err)
+	}
+
+	provider := &keyfileProvider{path: filePath, keys: make(map[string][]byte, len(encoded))}
+	for keyID, value := range encoded {
+		key, err := decodeAESKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", keyID, err)
+		}
+		provider.keys[keyID] = key
+	}
+	return provider, nil
+}
+
+// decodeAESKey decodes a base64 AES-256 key
+func decodeAESKey(value string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key has %d bytes, expected 32 for AES-256", len(key))
+	}
+	return key, nil
+}
+
+// newGCM creates an AES-GCM cipher for a 32-byte key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// associatedData binds a ciphertext to its field, the key and the rest of
+// the artifact: the interval and the metadata digest without the encrypted
+// parts. A ciphertext moved to another field or artifact, or left behind
+// when the metadata is edited, fails to decrypt.
+func associatedData(field string, encryption *ArtifactEncryption, interval int, torchData *TorchModelData) ([]byte, error) {
+	canonical := *torchData
+	if encryption.FeatureInfo {
+		canonical.FeatureInfo = FeatureInfo{}
+	}
+	digest, err := metadataDigest(&canonical)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%s%s\n%s\n%s\n%d\n%s\n", encryptionDomain, field, encryption.Algorithm, encryption.KeyID, interval, digest)), nil
+}
+
+// sealPayload encrypts plaintext with a random nonce, returning the nonce
+// followed by the ciphertext
+func sealPayload(key []byte, plaintext []byte, associatedData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize(), gcm.NonceSize()+len(plaintext)+gcm.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+// openPayload decrypts the output of sealPayload
+func openPayload(key []byte, sealed []byte, associatedData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed; wrong key, or the ciphertext or artifact was modified")
+	}
+	return plaintext, nil
+}
+
+// decryptArtifact decrypts the encrypted parts of an artifact in memory,
+// leaving the model in ModelBytes and the FeatureInfo in place. interval is
+// the artifact's outer interval.
+func decryptArtifact(torchData *TorchModelData, interval int, provider KeyProvider) error {
+	encryption := torchData.Encryption
+	if encryption == nil {
+		return nil
+	}
+	if encryption.Algorithm != EncryptionAES256GCM {
+		return fmt.Errorf("unsupported encryption algorithm %q", encryption.Algorithm)
+	}
+	if provider == nil {
+		return fmt.Errorf("artifact is encrypted with key %q; a key provider is required", encryption.KeyID)
+	}
+	key, err := provider.Key(encryption.KeyID)
+	if err != nil {
+		return err
+	}
+	// Both associated data are taken before either part is decrypted
+	modelAssociatedData, err := associatedData(modelEncryptedField, encryption, interval, torchData)
+	if err != nil {
+		return err
+	}
+	featureInfoAssociatedData, err := associatedData(featureInfoEncryptedField, encryption, interval, torchData)
+	if err != nil {
+		return err
+	}
+
+	if encryption.Model {
+		sealed := torchData.EncryptedModel
+		if sealed == nil {
+			if sealed, err = base64.StdEncoding.DecodeString(torchData.TorchModel.Model); err != nil {
+				return fmt.Errorf("failed to decode model: %w", err)
+			}
+		}
+		payload, err := openPayload(key, sealed, modelAssociatedData)
+		if err != nil {
+			return fmt.Errorf("model: %w", err)
+		}
+		if torchData.ModelBytes, err = decompressModel(payload, torchData.TorchModel.Compression); err != nil {
+			return err
+		}
+		torchData.TorchModel.Model = ""
+		torchData.EncryptedModel = nil
+	}
+
+	if encryption.FeatureInfo {
+		sealed, err := base64.StdEncoding.DecodeString(torchData.EncryptedFeatureInfo)
+		if err != nil {
+			return fmt.Errorf("failed to decode encrypted_feature_info: %w", err)
+		}
+		plaintext, err := openPayload(key, sealed, featureInfoAssociatedData)
+		if err != nil {
+			return fmt.Errorf("feature_info: %w", err)
+		}
+		torchData.FeatureInfo = FeatureInfo{}
+		if err := json.Unmarshal(plaintext, &torchData.FeatureInfo); err != nil {
+			return fmt.Errorf("failed to parse decrypted feature_info: %w", err)
+		}
+		if err := applyFeatureInfoMigration(torchData, plaintext); err != nil {
+			return err
+		}
+		torchData.EncryptedFeatureInfo = ""
+	}
+	return nil
+}
+
+// runEncrypt encrypts the model payload, and optionally the FeatureInfo, of
+// a model.json artifact. Every other value is kept verbatim.
+func runEncrypt(args []string) {
+	flags := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	modelPath := flags.String("model", "data/model.json", "path to the model JSON file to encrypt")
+	outPath := flags.String("out", "", "path of the encrypted model JSON file")
+	keys := flags.String("keys", "", "key provider: a keyfile path, file:PATH or env:PREFIX")
+	keyID := flags.String("key-id", "", "ID of the encryption key")
+	withFeatureInfo := flags.Bool("feature-info", false, "also encrypt feature_info")
+	flags.Parse(args)
+
+	if *outPath == "" || *keys == "" || *keyID == "" {
+		log.Fatalf("Invalid flags: -out, -keys and -key-id are required")
+	}
+	if isModelBundle(*modelPath) {
+		log.Fatalf("Only model JSON files can be encrypted; pack %s first", *modelPath)
+	}
+	provider, err := newKeyProvider(*keys)
+	if err != nil {
+		log.Fatalf("Failed to load keys: %v", err)
+	}
+	key, err := provider.Key(*keyID)
+	if err != nil {
+		log.Fatalf("Failed to load key: %v", err)
+	}
+
+	artifact, err := readArtifactFile(*modelPath)
+	if err != nil {
+		log.Fatalf("Failed to read artifact: %v", err)
+	}
+	encrypted, err := encryptArtifact(artifact, key, *keyID, *withFeatureInfo)
+	if err != nil {
+		log.Fatalf("Failed to encrypt %s: %v", *modelPath, err)
+	}
+	if err := writeArtifactFile(*outPath, encrypted); err != nil {
+		log.Fatalf("Failed to write encrypted artifact: %v", err)
+	}
+	fmt.Printf("Encrypted %s into %s with key %q\n", *modelPath, *outPath, *keyID)
+}
+
+// encryptArtifact encrypts the stored model payload, after any compression,
+// and optionally the raw feature_info of a model.json artifact
+func encryptArtifact(artifact []byte, key []byte, keyID string, withFeatureInfo bool) ([]byte, error) {
+	outer, inner, err := parseRawArtifact(artifact)
+	if err != nil {
+		return nil, err
+	}
+	if _, encrypted := inner["encryption"]; encrypted {
+		return nil, fmt.Errorf("artifact is already encrypted")
+	}
+	modelData, torchData, err := parseModelData(artifact)
+	if err != nil {
+		return nil, err
+	}
+	encryption := ArtifactEncryption{Algorithm: EncryptionAES256GCM, KeyID: keyID, Model: true, FeatureInfo: withFeatureInfo}
+	modelAssociatedData, err := associatedData(modelEncryptedField, &encryption, modelData.Interval, torchData)
+	if err != nil {
+		return nil, err
+	}
+	featureInfoAssociatedData, err := associatedData(featureInfoEncryptedField, &encryption, modelData.Interval, torchData)
+	if err != nil {
+		return nil, err
+	}
+
+	var torchModel map[string]json.RawMessage
+	if err := json.Unmarshal(inner["torch_model"], &torchModel); err != nil {
+		return nil, fmt.Errorf("failed to parse torch_model: %w", err)
+	}
+	var payload string
+	if err := json.Unmarshal(torchModel["model"], &payload); err != nil {
+		return nil, fmt.Errorf("failed to read torch_model.model: %w", err)
+	}
+	stored, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode model: %w", err)
+	}
+	sealed, err := sealPayload(key, stored, modelAssociatedData)
+	if err != nil {
+		return nil, err
+	}
+	if torchModel["model"], err = marshalJSON(base64.StdEncoding.EncodeToString(sealed), ""); err != nil {
+		return nil, err
+	}
+	if inner["torch_model"], err = marshalJSON(torchModel, ""); err != nil {
+		return nil, err
+	}
+
+	if withFeatureInfo {
+		featureInfo, exists := inner["feature_info"]
+		if !exists {
+			return nil, fmt.Errorf("artifact has no feature_info")
+		}
+		sealed, err := sealPayload(key, featureInfo, featureInfoAssociatedData)
+		if err != nil {
+			return nil, err
+		}
+		if inner["encrypted_feature_info"], err = marshalJSON(base64.StdEncoding.EncodeToString(sealed), ""); err != nil {
+			return nil, err
+		}
+		delete(inner, "feature_info")
+	}
+	if inner["encryption"], err = marshalJSON(encryption, ""); err != nil {
+		return nil, err
+	}
+
+	return encodeRawArtifact(outer, inner)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+)
+
+// runEvaluate scores the model against ground-truth labels from a labelled
+// dataset and reports quality next to Go-vs-Python parity on the artifact's
+// validation data
+func runEvaluate(args []string) {
+	flags := flag.NewFlagSet("evaluate", flag.ExitOnError)
+	artifact := addArtifactFlags(flags)
+	dataPath := flags.String("data", "", "labelled dataset (.jsonl or .csv); defaults to the artifact's validation data, which must then carry the target column")
+	batchSize := flags.Int("batch-size", 1024, "number of samples per forward pass")
+	encoding := addEncodingFlags(flags)
+	parityOptions := addParityFlags(flags)
+	sliceOptions := addSliceFlags(flags)
+	flags.Parse(args)
+
+	_, torchData, err := artifact.load()
+	if err != nil {
+		log.Fatalf("Failed to load model data: %v", err)
+	}
+
+	targetColumn := torchData.FeatureInfo.TargetColumn
+	if targetColumn == "" {
+		log.Fatalf("Artifact has no target_column in feature_info")
+	}
+
+	options, err := encoding.options(torchData.FeatureInfo)
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	checker, err := parityOptions.checker(torchData.ValidationTolerance)
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	sliceSpecs, err := parseSliceSpecs(*sliceOptions.by, torchData.FeatureInfo)
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+
+	samples := torchData.ValidationData
+	source := "artifact validation data"
+	if *dataPath != "" {
+		samples, err = loadDataset(*dataPath)
+		if err != nil {
+			log.Fatalf("Failed to load dataset: %v", err)
+		}
+		source = *dataPath
+	}
+	if len(samples) == 0 {
+		log.Fatalf("Dataset %s is empty", source)
+	}
+	// Validation data is usually exported without its labels
+	if *dataPath == "" && !hasColumn(samples, targetColumn) {
+		log.Fatalf("Artifact validation data has no %s column; pass a labelled dataset with -data", targetColumn)
+	}
+
+	labels, err := extractColumn(samples, targetColumn)
+	if err != nil {
+		log.Fatalf("Failed to read target column %s: %v", targetColumn, err)
+	}
+
+	model, err := artifact.loadModel(torchData)
+	if err != nil {
+		log.Fatalf("Failed to load PyTorch model: %v", err)
+	}
+	defer model.Free()
+
+	fmt.Printf("Evaluating %d samples from %s (target: %s, task: %s)\n", len(samples), source, targetColumn, torchData.TaskType)
+
+	predictions, err := predictSamples(model, samples, torchData.FeatureInfo, options, *batchSize)
+	if err != nil {
+		log.Fatalf("Failed to run inference: %v", err)
+	}
+	// Parity is only defined on the artifact's validation data, which
+	// carries the Python predictions
+	var validationPredictions []float64
+	if len(torchData.ValidationData) > 0 {
+		validationPredictions, err = predictSamples(model, torchData.ValidationData, torchData.FeatureInfo, options, *batchSize)
+		if err != nil {
+			log.Fatalf("Failed to run inference on validation data: %v", err)
+		}
+	}
+	scale, err := decideScoreScale(torchData.TaskType, torchData.TorchModel.Config, len(predictions)/len(samples), predictions, validationPredictions, torchData.ValidationPredictions)
+	if err != nil {
+		log.Fatalf("Failed to interpret model outputs: %v", err)
+	}
+	if scale != "" {
+		fmt.Printf("Reading model outputs as %s\n", scale)
+	}
+
+	weights, err := sampleWeights(samples, torchData.WeightColumn)
+	if err != nil {
+		log.Fatalf("Failed to read sample weights: %v", err)
+	}
+	columns, err := metricColumns("Quality", torchData.TaskType, scale, predictions, labels, weights, torchData.WeightColumn)
+	if err != nil {
+		log.Fatalf("Failed to compute quality metrics: %v", err)
+	}
+
+	for _, spec := range sliceSpecs {
+		slices, err := computeSlices(spec, samples, torchData.TaskType, scale, predictions, labels, weights, nil)
+		if err != nil {
+			log.Fatalf("Failed to compute quality slices: %v", err)
+		}
+		sliceOptions.print("Quality", spec, slices, torchData.TaskType, false)
+	}
+
+	var parity *ParityResult
+	if len(torchData.ValidationData) > 0 {
+		parity, err = checker.Compare(validationPredictions, torchData.ValidationPredictions)
+		if err != nil {
+			log.Fatalf("Failed to compare predictions: %v", err)
+		}
+		targets, err := referenceTargets(torchData.TaskType, scale, torchData.ValidationPredictions, len(torchData.ValidationData))
+		if err != nil {
+			log.Fatalf("Failed to derive parity targets: %v", err)
+		}
+		validationWeights, err := sampleWeights(torchData.ValidationData, torchData.WeightColumn)
+		if err != nil {
+			log.Fatalf("Failed to read validation sample weights: %v", err)
+		}
+		parityColumns, err := metricColumns("Parity", torchData.TaskType, scale, validationPredictions, targets, validationWeights, torchData.WeightColumn)
+		if err != nil {
+			log.Fatalf("Failed to compute parity metrics: %v", err)
+		}
+		columns = append(columns, parityColumns...)
+
+		for _, spec := range sliceSpecs {
+			slices, err := computeSlices(spec, torchData.ValidationData, torchData.TaskType, scale, validationPredictions, targets, validationWeights, parity)
+			if err != nil {
+				log.Fatalf("Failed to compute parity slices: %v", err)
+			}
+			sliceOptions.print("Parity", spec, slices, torchData.TaskType, true)
+		}
+	}
+
+	fmt.Printf("\n=== Quality vs Parity ===\n")
+	fmt.Printf("Quality: Go predictions vs %s labels (%d samples)\n", targetColumn, len(labels))
+	fmt.Printf("Parity: Go vs Python predictions on validation data (%d samples)\n\n", len(torchData.ValidationData))
+	printMetricsTable(columns)
+
+	if parity != nil {
+		fmt.Printf("\nParity: %d exact, %d close, %d different (tolerance %s)\n",
+			parity.Exact, parity.Close, parity.Diff, parity.ToleranceString())
+	} else {
+		fmt.Printf("\nParity: artifact has no validation data\n")
+	}
+}
@@ -2,12 +2,53 @@ package main
 
 import (
 	"fmt"
+	"strings"
 )
 
-// prepareValidationInput prepares input tensors from validation data
-func prepareValidationInput(validationData []ValidationData, featureInfo FeatureInfo) (*TorchTensor, *TorchTensor, error) {
+// EncodingOptions controls how raw feature values are encoded into tensors
+type EncodingOptions struct {
+	// Conversion decides whether numerical values that cannot be encoded
+	// abort encoding or fall back to 0 with a warning
+	Conversion ConversionMode
+	// Canonicalizer rewrites categorical values before label encoding; nil disables it
+	Canonicalizer *Canonicalizer
+	// Verbose prints the expected features and the first encoded samples
+	Verbose bool
+	// SampleOffset is the dataset index of the first sample being encoded,
+	// so messages number samples across batches
+	SampleOffset int
+}
+
+// EncodedBatch holds the model inputs for a batch of samples before they
+// become tensors, in row-major [samples, features] layout
+type EncodedBatch struct {
+	NumSamples          int
+	NumericalFeatures   []string
+	CategoricalFeatures []string
+	Numerical           []float32
+	Categorical         []int64
+	// CategoricalVocabSizes bounds the indices of each categorical feature,
+	// in CategoricalFeatures order
+	CategoricalVocabSizes []int
+	// SampleOffset is the dataset index of the first sample in the batch
+	SampleOffset int
+}
+
+// prepareValidationInput prepares input tensors from validation data.
+// Numerical features are passed through the transforms declared in
+// featureInfo, categorical values through the canonicalizer.
+func prepareValidationInput(validationData []ValidationData, featureInfo FeatureInfo, options EncodingOptions) (*TorchTensor, *TorchTensor, error) {
+	batch, err := encodeSamples(validationData, featureInfo, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	return batch.toTensors()
+}
+
+// encodeSamples encodes the numerical and categorical features of every sample
+func encodeSamples(validationData []ValidationData, featureInfo FeatureInfo, options EncodingOptions) (*EncodedBatch, error) {
 	if len(validationData) == 0 {
-		return nil, nil, fmt.Errorf("no validation data provided")
+		return nil, fmt.Errorf("no validation data provided")
 	}
 
 	batchSize := len(validationData)
@@ -19,70 +60,142 @@ func prepareValidationInput(validationData []ValidationData, featureInfo Feature
 	numNumericalFeatures := len(numericalFeatures)
 	numCategoricalFeatures := len(categoricalFeatures)
 
-	fmt.Printf("Model expects %d numerical features: %v\n", numNumericalFeatures, numericalFeatures)
-	fmt.Printf("Model expects %d categorical features: %v\n", numCategoricalFeatures, categoricalFeatures)
-
-	// Prepare feature data arrays
-	var numericalData []float32
-	var categoricalData []float32
+	if options.Verbose {
+		fmt.Printf("Model expects %d numerical features: %v\n", numNumericalFeatures, numericalFeatures)
+		fmt.Printf("Model expects %d categorical features: %v\n", numCategoricalFeatures, categoricalFeatures)
+	}
 
-	if numNumericalFeatures > 0 {
-		numericalData = make([]float32, batchSize*numNumericalFeatures)
+	batch := &EncodedBatch{
+		NumSamples:          batchSize,
+		NumericalFeatures:   numericalFeatures,
+		CategoricalFeatures: categoricalFeatures,
+		Numerical:           make([]float32, batchSize*numNumericalFeatures),
+		Categorical:         make([]int64, batchSize*numCategoricalFeatures),
+		SampleOffset:        options.SampleOffset,
 	}
-	if numCategoricalFeatures > 0 {
-		categoricalData = make([]float32, batchSize*numCategoricalFeatures)
+	for _, featureName := range categoricalFeatures {
+		vocabSize, exists := featureInfo.CategoricalVocabSizes[featureName]
+		if !exists {
+			vocabSize = len(featureInfo.MissingValueHandling.LabelEncoders[featureName].Classes)
+		}
+		batch.CategoricalVocabSizes = append(batch.CategoricalVocabSizes, vocabSize)
 	}
 
 	// Process each sample
 	for i, sample := range validationData {
+		sampleNumber := options.SampleOffset + i + 1
+
 		// Process numerical features
 		if numNumericalFeatures > 0 {
 			numericalOffset := i * numNumericalFeatures
 			for j, featureName := range numericalFeatures {
 				value, err := getFeatureValue(sample, featureName)
 				if err != nil {
-					return nil, nil, fmt.Errorf("failed to get numerical feature %s: %v", featureName, err)
+					return nil, fmt.Errorf("sample %d: failed to get numerical feature %s: %v", sampleNumber, featureName, err)
 				}
-				numericalData[numericalOffset+j] = convertToFloat32(value)
+				converted, err := encodeNumerical(value, featureInfo.Transforms[featureName], options.Conversion)
+				if err != nil {
+					if options.Conversion == StrictConversion {
+						return nil, fmt.Errorf("sample %d: failed to encode numerical feature %s: %v", sampleNumber, featureName, err)
+					}
+					fmt.Printf("WARNING: sample %d: cannot encode numerical feature %s (%v), using 0\n", sampleNumber, featureName, err)
+				}
+				batch.Numerical[numericalOffset+j] = converted
 			}
 		}
 
 		// Process categorical features
 		if numCategoricalFeatures > 0 {
 			categoricalOffset := i * numCategoricalFeatures
-			var categoricalIndices []int
 
 			for j, featureName := range categoricalFeatures {
 				encoder, exists := featureInfo.MissingValueHandling.LabelEncoders[featureName]
 				if !exists {
-					return nil, nil, fmt.Errorf("no encoder found for categorical feature: %s", featureName)
+					return nil, fmt.Errorf("no encoder found for categorical feature: %s", featureName)
 				}
 
 				value, err := getFeatureValue(sample, featureName)
 				if err != nil {
-					return nil, nil, fmt.Errorf("failed to get categorical feature %s: %v", featureName, err)
+					return nil, fmt.Errorf("sample %d: failed to get categorical feature %s: %v", sampleNumber, featureName, err)
 				}
 
 				valueStr := fmt.Sprintf("%v", value) // Convert to string for encoding
-				index := encodeCategoricalFromEncoder(valueStr, encoder)
-				categoricalData[categoricalOffset+j] = float32(index)
-				categoricalIndices = append(categoricalIndices, index)
+				if options.Canonicalizer != nil {
+					valueStr = options.Canonicalizer.Canonicalize(featureName, valueStr, encoder)
+				}
+				batch.Categorical[categoricalOffset+j] = int64(encodeCategoricalFromEncoder(valueStr, encoder))
 			}
 
 			// Debug: Show first few samples
-			if i < 3 {
-				fmt.Printf("Sample %d categorical indices: %v\n", i+1, categoricalIndices)
+			if options.Verbose && i < 3 {
+				fmt.Printf("Sample %d categorical indices: %v\n", sampleNumber, batch.CategoricalRow(i))
 			}
 		}
 	}
 
+	return batch, nil
+}
+
+// NumericalRow returns the encoded numerical features of one sample
+func (b *EncodedBatch) NumericalRow(sample int) []float32 {
+	width := len(b.NumericalFeatures)
+	return b.Numerical[sample*width : (sample+1)*width]
+}
+
+// CategoricalRow returns the encoded categorical indices of one sample
+func (b *EncodedBatch) CategoricalRow(sample int) []int64 {
+	width := len(b.CategoricalFeatures)
+	return b.Categorical[sample*width : (sample+1)*width]
+}
+
+// checkCategoricalBounds checks every categorical index against its
+// feature's vocabulary size, so out-of-range indices never reach the
+// model's embedding tables. The error lists every offending sample,
+// grouped by feature.
+func (b *EncodedBatch) checkCategoricalBounds() error {
+	width := len(b.CategoricalFeatures)
+	violations := make([][]string, width)
+	total := 0
+	for i, index := range b.Categorical {
+		vocabSize := b.CategoricalVocabSizes[i%width]
+		if index >= 0 && index < int64(vocabSize) {
+			continue
+		}
+		violations[i%width] = append(violations[i%width], fmt.Sprintf("sample %d (index %d)", b.SampleOffset+i/width+1, index))
+		total++
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var lines []string
+	for feature, samples := range violations {
+		if len(samples) > 0 {
+			lines = append(lines, fmt.Sprintf("  %s, vocabulary [0, %d): %s",
+				b.CategoricalFeatures[feature], b.CategoricalVocabSizes[feature], strings.Join(samples, ", ")))
+		}
+	}
+	return fmt.Errorf("%d categorical indices outside their vocabulary:\n%s", total, strings.Join(lines, "\n"))
+}
+
+// toTensors creates the numerical and categorical input tensors after
+// bounds-checking the categorical indices
+func (b *EncodedBatch) toTensors() (*TorchTensor, *TorchTensor, error) {
+	if err := b.checkCategoricalBounds(); err != nil {
+		return nil, nil, err
+	}
+
+	batchSize := b.NumSamples
+	numNumericalFeatures := len(b.NumericalFeatures)
+	numCategoricalFeatures := len(b.CategoricalFeatures)
+
 	// Create numerical tensor
 	var numericalTensor *TorchTensor
 	var err error
 
 	if numNumericalFeatures > 0 {
 		numericalDims := []int64{int64(batchSize), int64(numNumericalFeatures)}
-		numericalTensor, err = createTensorFromData(numericalData, numericalDims)
+		numericalTensor, err = createTensorFromData(b.Numerical, numericalDims)
 		if err != nil {
 			return nil, nil, fmt.Errorf("failed to create numerical tensor: %v", err)
 		}
@@ -101,8 +214,13 @@ func prepareValidationInput(validationData []ValidationData, featureInfo Feature
 
 	if numCategoricalFeatures > 0 {
 		categoricalDims := []int64{int64(batchSize), int64(numCategoricalFeatures)}
+		categoricalData := make([]float32, len(b.Categorical))
+		for i, index := range b.Categorical {
+			categoricalData[i] = float32(index)
+		}
 		categoricalTensor, err = createIntTensorFromData(categoricalData, categoricalDims)
 		if err != nil {
+			numericalTensor.Free()
 			return nil, nil, fmt.Errorf("failed to create categorical tensor: %v", err)
 		}
 	} else {
@@ -111,9 +229,67 @@ func prepareValidationInput(validationData []ValidationData, featureInfo Feature
 		dummyCategoricalData := make([]float32, 1) // Minimum size for tensor creation
 		categoricalTensor, err = createIntTensorFromData(dummyCategoricalData, categoricalDims)
 		if err != nil {
+			numericalTensor.Free()
 			return nil, nil, fmt.Errorf("failed to create empty categorical tensor: %v", err)
 		}
 	}
 
 	return numericalTensor, categoricalTensor, nil
 }
+
+// encodeNumerical converts a raw numerical value and applies the feature's
+// transforms in float64 before narrowing the result to float32
+func encodeNumerical(value interface{}, transforms []FeatureTransform, mode ConversionMode) (float32, error) {
+	f, err := convertNumerical(value, mode)
+	if err != nil {
+		return 0, err
+	}
+	f, err = applyTransforms(f, transforms)
+	if err != nil {
+		return 0, err
+	}
+	return narrowToFloat32(f)
+}
+
+// predictSamples encodes samples and runs the model on them in batches,
+// returning the flattened model outputs
+func predictSamples(model *TorchModule, samples []ValidationData, featureInfo FeatureInfo, options EncodingOptions, batchSize int) ([]float64, error) {
+	if batchSize <= 0 {
+		batchSize = len(samples)
+	}
+
+	var predictions []float64
+	for start := 0; start < len(samples); start += batchSize {
+		end := start + batchSize
+		if end > len(samples) {
+			end = len(samples)
+		}
+
+		batchOptions := options
+		batchOptions.SampleOffset += start
+		batch, err := predictBatch(model, samples[start:end], featureInfo, batchOptions)
+		if err != nil {
+			return nil, fmt.Errorf("samples %d-%d: %w", start+1, end, err)
+		}
+		predictions = append(predictions, batch...)
+	}
+	return predictions, nil
+}
+
+// predictBatch encodes one batch of samples and runs a forward pass
+func predictBatch(model *TorchModule, samples []ValidationData, featureInfo FeatureInfo, options EncodingOptions) ([]float64, error) {
+	numericalTensor, categoricalTensor, err := prepareValidationInput(samples, featureInfo, options)
+	if err != nil {
+		return nil, err
+	}
+	defer numericalTensor.Free()
+	defer categoricalTensor.Free()
+
+	outputTensor, err := model.Forward(numericalTensor, categoricalTensor)
+	if err != nil {
+		return nil, err
+	}
+	defer outputTensor.Free()
+
+	return outputTensor.ToFloat64Slice()
+}
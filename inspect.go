@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// runInspect prints what an artifact describes: its storage, the typed
+// model and training configs, the features and the result of the
+// consistency checks. Inconsistent artifacts are shown rather than refused.
+func runInspect(args []string) {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	artifact := addArtifactFlags(flags)
+	showParams := flags.Bool("params", false, "also load the TorchScript module and list its parameter shapes")
+	flags.Parse(args)
+
+	options, err := artifact.options()
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	modelData, torchData, err := loadModelData(*artifact.model)
+	if err != nil {
+		log.Fatalf("Failed to load model data: %v", err)
+	}
+	if err := decryptArtifact(torchData, modelData.Interval, options.KeyProvider); err != nil {
+		log.Fatalf("Failed to decrypt artifact: %v", err)
+	}
+
+	fmt.Printf("Artifact: %s\n", *artifact.model)
+	fmt.Printf("- Interval: %d\n", modelData.Interval)
+	switch {
+	case torchData.LegacyLayout:
+		fmt.Printf("- Schema Version: legacy layout, migrated to %d\n", CurrentSchemaVersion)
+	case torchData.SchemaVersion == 0:
+		fmt.Printf("- Schema Version: unversioned, read as %d\n", CurrentSchemaVersion)
+	default:
+		fmt.Printf("- Schema Version: %d\n", torchData.SchemaVersion)
+	}
+	if isModelBundle(*artifact.model) {
+		fmt.Printf("- Format: model directory\n")
+	} else {
+		fmt.Printf("- Format: model.json\n")
+	}
+	if modelBytes, err := decodeModelBytes(torchData); err == nil {
+		fmt.Printf("- Model Size: %d bytes\n", len(modelBytes))
+	}
+	if torchData.TorchModel.Compression != "" {
+		fmt.Printf("- Model Compression: %s\n", torchData.TorchModel.Compression)
+	}
+	if encryption := torchData.Encryption; encryption != nil {
+		fmt.Printf("- Encryption: %s with key %q (model: %t, feature info: %t)\n", encryption.Algorithm, encryption.KeyID, encryption.Model, encryption.FeatureInfo)
+	}
+	switch integrity := torchData.Integrity; {
+	case integrity == nil:
+		fmt.Printf("- Integrity: none\n")
+	case integrity.Signature == "":
+		fmt.Printf("- Integrity: digests, unsigned\n")
+	default:
+		fmt.Printf("- Integrity: digests, signed by %q\n", integrity.KeyID)
+	}
+
+	fmt.Printf("\nHyperparameters:\n")
+	fmt.Printf("- Task Type: %s\n", torchData.TaskType)
+	fmt.Printf("- Learning Rate: %g\n", torchData.LearningRate)
+	fmt.Printf("- Weight Decay: %g\n", torchData.WeightDecay)
+	fmt.Printf("- Epochs: %d\n", torchData.Epochs)
+	fmt.Printf("- Batch Size: %d\n", torchData.BatchSize)
+
+	fmt.Printf("\nModel Config:\n")
+	modelConfig, err := parseModelConfig(torchData.TorchModel.Config)
+	if err != nil {
+		fmt.Printf("- %v\n", err)
+		modelConfig = &ModelConfig{}
+	} else {
+		printModelConfig(modelConfig)
+	}
+	if len(torchData.ModelSpecificKwargs) > 0 {
+		fmt.Printf("\nModel-Specific Kwargs:\n")
+		printModelSpecificKwargs(torchData.ModelSpecificKwargs, modelConfig)
+	}
+
+	history := torchData.TrainingHistory
+	fmt.Printf("\nTraining:\n")
+	if trainingConfig, err := parseTrainingConfig(history.Config); err != nil {
+		fmt.Printf("- %v\n", err)
+	} else {
+		printTrainingConfig(trainingConfig)
+	}
+	if losses := history.TrainLosses; len(losses) > 0 {
+		fmt.Printf("- Train Losses: %d epochs, first %.6f, final %.6f\n", len(losses), losses[0], losses[len(losses)-1])
+	}
+	if history.TotalTrainingTime > 0 {
+		fmt.Printf("- Total Training Time: %.1fs\n", history.TotalTrainingTime)
+	}
+
+	featureInfo := torchData.FeatureInfo
+	fmt.Printf("\nFeatures:\n")
+	for _, featureName := range featureInfo.FeatureNames["categorical"] {
+		fmt.Printf("- %s: categorical, vocabulary %d\n", featureName, featureInfo.CategoricalVocabSizes[featureName])
+	}
+	for _, featureName := range featureInfo.FeatureNames["numerical"] {
+		steps := make([]string, 0, len(featureInfo.Transforms[featureName]))
+		for _, transform := range featureInfo.Transforms[featureName] {
+			steps = append(steps, transform.Type)
+		}
+		if len(steps) == 0 {
+			fmt.Printf("- %s: numerical\n", featureName)
+		} else {
+			fmt.Printf("- %s: numerical, %s\n", featureName, strings.Join(steps, " -> "))
+		}
+	}
+	if featureInfo.TargetColumn != "" {
+		fmt.Printf("- Target: %s\n", featureInfo.TargetColumn)
+	}
+
+	fmt.Printf("\nValidation:\n")
+	fmt.Printf("- Samples: %d\n", len(torchData.ValidationData))
+	fmt.Printf("- Predictions: %d\n", len(torchData.ValidationPredictions))
+	fmt.Printf("- Tolerance: %g\n", torchData.ValidationTolerance)
+
+	problems := checkArtifact(torchData)
+	fmt.Printf("\nConsistency Checks:\n")
+	if len(problems) == 0 {
+		fmt.Printf("- OK\n")
+	}
+	for _, problem := range problems {
+		fmt.Printf("- %s\n", problem)
+	}
+
+	if !*showParams {
+		return
+	}
+	options.Force = true
+	model, err := loadTorchModel(torchData, options)
+	if err != nil {
+		log.Fatalf("Failed to load PyTorch model: %v", err)
+	}
+	defer model.Free()
+	params, err := model.Parameters()
+	if err != nil {
+		log.Fatalf("Failed to read module parameters: %v", err)
+	}
+	fmt.Printf("\nModule Parameters:\n")
+	for _, param := range params {
+		fmt.Printf("- %s: %v\n", param.Name, param.Shape)
+	}
+}
+
+// printModelConfig prints the keys a model config sets, then any keys this
+// tool does not interpret
+func printModelConfig(config *ModelConfig) {
+	if config.Has("num_numerical_features") {
+		fmt.Printf("- Numerical Features: %d\n", config.NumNumericalFeatures)
+	}
+	if config.Has("num_categorical_features") {
+		fmt.Printf("- Categorical Features: %d\n", config.NumCategoricalFeatures)
+	}
+	if config.Has("categorical_vocab_sizes") {
+		fmt.Printf("- Embedding Tables: %d, sizes %v\n", len(config.CategoricalVocabSizes), config.CategoricalVocabSizes)
+	}
+	if config.Has("embedding_dim") {
+		fmt.Printf("- Embedding Dim: %d\n", config.EmbeddingDim)
+	}
+	if config.Has("hidden_dims") {
+		fmt.Printf("- Hidden Layers: %v\n", config.HiddenDims)
+	}
+	if config.Has("dropout_rate") {
+		fmt.Printf("- Dropout Rate: %g\n", config.DropoutRate)
+	}
+	if config.Has("num_classes") {
+		fmt.Printf("- Classes: %d\n", config.NumClasses)
+	}
+	if config.Has("task_type") {
+		fmt.Printf("- Task Type: %s\n", config.TaskType)
+	}
+	printExtraConfig(config.Extra())
+	if len(config.fields) == 0 {
+		fmt.Printf("- none\n")
+	}
+}
+
+// printTrainingConfig prints the keys a training config sets, then any keys
+// this tool does not interpret
+func printTrainingConfig(config *TrainingConfig) {
+	if config.Has("epochs") {
+		fmt.Printf("- Epochs: %d\n", config.Epochs)
+	}
+	if config.Has("learning_rate") {
+		fmt.Printf("- Learning Rate: %g\n", config.LearningRate)
+	}
+	if config.Has("weight_decay") {
+		fmt.Printf("- Weight Decay: %g\n", config.WeightDecay)
+	}
+	if config.Has("batch_size") {
+		fmt.Printf("- Batch Size: %d\n", config.BatchSize)
+	}
+	printExtraConfig(config.Extra())
+}
+
+// printModelSpecificKwargs prints the model_specific_kwargs, marking the
+// keys torch_model.config does not set, which are not interpreted
+func printModelSpecificKwargs(kwargs map[string]json.RawMessage, config *ModelConfig) {
+	keys := make([]string, 0, len(kwargs))
+	for key := range kwargs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if config.Has(key) {
+			fmt.Printf("- %s: %s\n", key, kwargs[key])
+		} else {
+			fmt.Printf("- %s: %s (not in torch_model.config, not interpreted)\n", key, kwargs[key])
+		}
+	}
+}
+
+// printExtraConfig prints uninterpreted config keys with their raw values
+func printExtraConfig(extra map[string]json.RawMessage) {
+	keys := make([]string, 0, len(extra))
+	for key := range extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Printf("- %s: %s\n", key, extra[key])
+	}
+}
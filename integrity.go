@@ -0,0 +1,348 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// signatureDomain prefixes every signed message, so artifact signatures
+// cannot be replayed as signatures over anything else
+const signatureDomain = "go-torch-demo artifact integrity v1\n"
+
+// ArtifactIntegrity holds the digests of an artifact and an optional
+// signature over them. Digests are hex-encoded SHA-256.
+type ArtifactIntegrity struct {
+	// ModelSHA256 covers the decoded, decompressed TorchScript payload
+	ModelSHA256 string `json:"model_sha256"`
+	// MetadataSHA256 covers every other field, see metadataDigest
+	MetadataSHA256 string `json:"metadata_sha256"`
+	// KeyID names the trusted key that made Signature
+	KeyID string `json:"key_id,omitempty"`
+	// Signature is the base64 ed25519 signature of signedMessage
+	Signature string `json:"signature,omitempty"`
+}
+
+// modelDigest returns the hex SHA-256 of a TorchScript payload
+func modelDigest(modelBytes []byte) string {
+	sum := sha256.Sum256(modelBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// metadataDigest returns the hex SHA-256 of every artifact field except the
+// model payload, its encoding, the integrity block and the schema version.
+// Fields are digested as TorchModelData reads them: unknown top-level fields
+// are covered through Extra, but unknown fields nested inside known objects
+// such as feature_info or training_history are dropped by decoding and are
+// not covered.
+func metadataDigest(torchData *TorchModelData) (string, error) {
+	canonical := *torchData
+	canonical.TorchModel.Model = ""
+	canonical.TorchModel.Compression = ""
+	canonical.Integrity = nil
+	canonical.SchemaVersion = 0
+	canonical.Encryption = nil
+	canonical.EncryptedFeatureInfo = ""
+	data, err := json.Marshal(&canonical)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	// Fields TorchModelData does not read follow in key order, so artifacts
+	// without any keep their digest
+	if len(torchData.Extra) > 0 {
+		extra, err := json.Marshal(torchData.Extra)
+		if err != nil {
+			return "", fmt.Errorf("failed to encode metadata: %w", err)
+		}
+		data = append(append(data, '\n'), extra...)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// signedMessage returns the bytes an integrity signature covers
+func (i *ArtifactIntegrity) signedMessage() []byte {
+	return []byte(signatureDomain + i.KeyID + "\n" + i.ModelSHA256 + "\n" + i.MetadataSHA256 + "\n")
+}
+
+// computeIntegrity computes the digests of an artifact and, if key is not
+// nil, signs them as keyID
+func computeIntegrity(torchData *TorchModelData, modelBytes []byte, keyID string, key ed25519.PrivateKey) (*ArtifactIntegrity, error) {
+	metadata, err := metadataDigest(torchData)
+	if err != nil {
+		return nil, err
+	}
+	integrity := &ArtifactIntegrity{ModelSHA256: modelDigest(modelBytes), MetadataSHA256: metadata}
+	if key != nil {
+		integrity.KeyID = keyID
+		integrity.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, integrity.signedMessage()))
+	}
+	return integrity, nil
+}
+
+// verifyIntegrity checks the artifact digests and, when trusted keys are
+// configured, requires a valid signature by one of them. Artifacts without
+// an integrity block pass only when no trusted keys are configured.
+func verifyIntegrity(torchData *TorchModelData, modelBytes []byte, trustedKeys map[string]ed25519.PublicKey) error {
+	if err := verifyModelIntegrity(torchData, modelBytes); err != nil {
+		return err
+	}
+	return verifyMetadataIntegrity(torchData, trustedKeys)
+}
+
+// verifyModelIntegrity checks the model payload against its digest, if the
+// artifact has one
+func verifyModelIntegrity(torchData *TorchModelData, modelBytes []byte) error {
+	if torchData.Integrity == nil {
+		return nil
+	}
+	if !digestsEqual(modelDigest(modelBytes), torchData.Integrity.ModelSHA256) {
+		return fmt.Errorf("model payload SHA-256 does not match the artifact digest; the model is corrupted or was modified")
+	}
+	return nil
+}
+
+// verifyMetadataIntegrity checks the metadata digest and the signature over
+// both digests, which needs no model bytes. Artifacts without an integrity
+// block pass only when no trusted keys are configured.
+func verifyMetadataIntegrity(torchData *TorchModelData, trustedKeys map[string]ed25519.PublicKey) error {
+	integrity := torchData.Integrity
+	if integrity == nil {
+		if len(trustedKeys) > 0 {
+			return fmt.Errorf("artifact is unsigned but trusted keys are configured")
+		}
+		return nil
+	}
+
+	metadata, err := metadataDigest(torchData)
+	if err != nil {
+		return err
+	}
+	if !digestsEqual(metadata, integrity.MetadataSHA256) {
+		return fmt.Errorf("metadata SHA-256 does not match the artifact digest; the metadata is corrupted or was modified")
+	}
+
+	if len(trustedKeys) == 0 {
+		if integrity.Signature != "" {
+			fmt.Fprintf(os.Stderr, "WARNING: artifact is signed by %q but no trusted keys are configured; signature not checked\n", integrity.KeyID)
+		}
+		return nil
+	}
+	if integrity.Signature == "" {
+		return fmt.Errorf("artifact is not signed but trusted keys are configured")
+	}
+	publicKey, trusted := trustedKeys[integrity.KeyID]
+	if !trusted {
+		return fmt.Errorf("artifact is signed by untrusted key %q", integrity.KeyID)
+	}
+	signature, err := base64.StdEncoding.DecodeString(integrity.Signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature: %w", err)
+	}
+	if !ed25519.Verify(publicKey, integrity.signedMessage(), signature) {
+		return fmt.Errorf("signature by key %q is invalid", integrity.KeyID)
+	}
+	return nil
+}
+
+// digestsEqual compares hex digests case-insensitively in constant time
+func digestsEqual(a string, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(strings.ToLower(a)), []byte(strings.ToLower(b))) == 1
+}
+
+// loadTrustedKeys reads a JSON object mapping key IDs to base64 ed25519
+// public keys
+func loadTrustedKeys(filePath string) (map[string]ed25519.PublicKey, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+	}
+	var encoded map[string]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return nil, fmt.Errorf("failed to parse trusted keys: %w", err)
+	}
+	if len(encoded) == 0 {
+		return nil, fmt.Errorf("trusted keys file %s lists no keys", filePath)
+	}
+
+	keys := make(map[string]ed25519.PublicKey, len(encoded))
+	for keyID, value := range encoded {
+		key, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("trusted key %q is not a base64 ed25519 public key", keyID)
+		}
+		keys[keyID] = ed25519.PublicKey(key)
+	}
+	return keys, nil
+}
+
+// loadSigningKey reads a base64 ed25519 private key, either the 32-byte seed
+// or the 64-byte expanded key
+func loadSigningKey(filePath string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("signing key is not base64: %w", err)
+	}
+	switch len(key) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(key), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(key), nil
+	default:
+		return nil, fmt.Errorf("signing key has %d bytes, expected an ed25519 seed or private key", len(key))
+	}
+}
+
+// runSign adds digests and an optional signature to an artifact. Model
+// directories are signed in place through their metadata.json.
+func runSign(args []string) {
+	flags := flag.NewFlagSet("sign", flag.ExitOnError)
+	modelPath := flags.String("model", "data/model.json", "path to the model JSON file or model directory to sign")
+	outPath := flags.String("out", "", "path of the signed model JSON file (default: overwrite -model)")
+	keyPath := flags.String("key", "", "base64 ed25519 private key file; without it only digests are written")
+	keyID := flags.String("key-id", "", "ID of the signing key, as listed in trusted key files")
+	force := flags.Bool("force", false, "sign the artifact even if it fails the consistency checks")
+	keys := flags.String("keys", "", "decryption key provider for encrypted artifacts: a keyfile path, file:PATH or env:PREFIX")
+	flags.Parse(args)
+
+	var key ed25519.PrivateKey
+	if *keyPath != "" {
+		if *keyID == "" {
+			log.Fatalf("Invalid flags: -key-id is required with -key")
+		}
+		var err error
+		if key, err = loadSigningKey(*keyPath); err != nil {
+			log.Fatalf("Failed to load signing key: %v", err)
+		}
+	}
+
+	options := LoadOptions{Force: *force}
+	if *keys != "" {
+		provider, err := newKeyProvider(*keys)
+		if err != nil {
+			log.Fatalf("Failed to load keys: %v", err)
+		}
+		options.KeyProvider = provider
+	}
+	_, torchData, err := loadArtifact(*modelPath, options)
+	if err != nil {
+		log.Fatalf("Failed to load model data: %v", err)
+	}
+	modelBytes, err := decodeModelBytes(torchData)
+	if err != nil {
+		log.Fatalf("Failed to decode model: %v", err)
+	}
+	integrity, err := computeIntegrity(torchData, modelBytes, *keyID, key)
+	if err != nil {
+		log.Fatalf("Failed to compute digests: %v", err)
+	}
+
+	if isModelBundle(*modelPath) {
+		err = writeBundleIntegrity(*modelPath, integrity)
+	} else {
+		if *outPath == "" {
+			*outPath = *modelPath
+		}
+		err = writeArtifactIntegrity(*modelPath, *outPath, integrity)
+	}
+	if err != nil {
+		log.Fatalf("Failed to write signed artifact: %v", err)
+	}
+
+	fmt.Printf("Model SHA-256:    %s\n", integrity.ModelSHA256)
+	fmt.Printf("Metadata SHA-256: %s\n", integrity.MetadataSHA256)
+	if key != nil {
+		fmt.Printf("Signed with key %q\n", *keyID)
+	}
+}
+
+// writeArtifactIntegrity sets the integrity block of a model.json artifact,
+// keeping every other value verbatim
+func writeArtifactIntegrity(inPath string, outPath string, integrity *ArtifactIntegrity) error {
+	artifact, err := readArtifactFile(inPath)
+	if err != nil {
+		return err
+	}
+	outer, inner, err := parseRawArtifact(artifact)
+	if err != nil {
+		return err
+	}
+	if inner["integrity"], err = marshalJSON(integrity, ""); err != nil {
+		return err
+	}
+	signed, err := encodeRawArtifact(outer, inner)
+	if err != nil {
+		return err
+	}
+	return writeArtifactFile(outPath, signed)
+}
+
+// writeBundleIntegrity sets the integrity block in a model directory's
+// metadata.json
+func writeBundleIntegrity(path string, integrity *ArtifactIntegrity) error {
+	dir := path
+	if strings.EqualFold(filepath.Ext(path), ".pt") {
+		dir = filepath.Dir(path)
+	}
+	metadataPath := filepath.Join(dir, bundleMetadataFile)
+
+	metadata := make(map[string]json.RawMessage)
+	if data, err := os.ReadFile(metadataPath); err == nil {
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", bundleMetadataFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var err error
+	if metadata["integrity"], err = marshalJSON(integrity, ""); err != nil {
+		return err
+	}
+	data, err := marshalJSON(metadata, "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metadataPath, append(data, '\n'), 0644)
+}
+
+// runKeygen generates an ed25519 signing key and prints its trusted key entry
+func runKeygen(args []string) {
+	flags := flag.NewFlagSet("keygen", flag.ExitOnError)
+	outPath := flags.String("out", "", "path to write the base64 private key seed to")
+	keyID := flags.String("key-id", "", "ID of the key in trusted key files")
+	flags.Parse(args)
+
+	if *outPath == "" || *keyID == "" {
+		log.Fatalf("Invalid flags: -out and -key-id are required")
+	}
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	seed := base64.StdEncoding.EncodeToString(privateKey.Seed())
+	if err := os.WriteFile(*outPath, []byte(seed+"\n"), 0600); err != nil {
+		log.Fatalf("Failed to write key: %v", err)
+	}
+
+	entry, err := json.Marshal(map[string]string{*keyID: base64.StdEncoding.EncodeToString(publicKey)})
+	if err != nil {
+		log.Fatalf("Failed to encode public key: %v", err)
+	}
+	fmt.Printf("Private key written to %s\n", *outPath)
+	fmt.Printf("Trusted key entry: %s\n", entry)
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// signArtifact signs data/model.json with a new key as keyID and returns
+// the signed artifact and the trusted keys that verify it
+func signArtifact(t *testing.T, keyID string) ([]byte, map[string]ed25519.PublicKey) {
+	t.Helper()
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact, err := readArtifactFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, torchData, err := parseModelData(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelBytes, err := decodeModelBytes(torchData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrity, err := computeIntegrity(torchData, modelBytes, keyID, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedPath := filepath.Join(t.TempDir(), "signed.json")
+	if err := writeArtifactIntegrity("data/model.json", signedPath, integrity); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := readArtifactFile(signedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed, map[string]ed25519.PublicKey{keyID: publicKey}
+}
+
+// editArtifact returns artifact with its inner fields changed by edit
+func editArtifact(t *testing.T, artifact []byte, edit func(inner map[string]json.RawMessage)) []byte {
+	t.Helper()
+	outer, inner, err := parseRawArtifact(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edit(inner)
+	edited, err := encodeRawArtifact(outer, inner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return edited
+}
+
+func TestLoadArtifactVerifiesTrustedKeys(t *testing.T) {
+	signed, trustedKeys := signArtifact(t, "release")
+	_, otherKeys := signArtifact(t, "release")
+	unsigned, err := readArtifactFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := editArtifact(t, signed, func(inner map[string]json.RawMessage) {
+		inner["validation_tolerance"] = json.RawMessage("0.5")
+	})
+
+	tests := []struct {
+		name     string
+		artifact []byte
+		keys     map[string]ed25519.PublicKey
+		want     string
+	}{
+		{"signed by a trusted key", signed, trustedKeys, ""},
+		{"signed by another key with the same ID", signed, otherKeys, "is invalid"},
+		{"signed by an untrusted key ID", signed, map[string]ed25519.PublicKey{"ci": trustedKeys["release"]}, "untrusted key"},
+		{"unsigned", unsigned, trustedKeys, "unsigned"},
+		{"modified metadata", modified, trustedKeys, "metadata SHA-256"},
+		// Without trusted keys, only loading the model checks the digests
+		{"modified metadata without trusted keys", modified, nil, ""},
+	}
+	for _, tc := range tests {
+		path := filepath.Join(t.TempDir(), "model.json")
+		if err := writeArtifactFile(path, tc.artifact); err != nil {
+			t.Fatal(err)
+		}
+		_, _, err := loadArtifact(path, LoadOptions{TrustedKeys: tc.keys})
+		if tc.want == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestMetadataDigestCoverage(t *testing.T) {
+	artifact, err := readArtifactFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := func(artifact []byte) string {
+		_, torchData, err := parseModelData(artifact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, err := metadataDigest(torchData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sum
+	}
+	// withFeatureInfoField adds a field to the feature_info object
+	withFeatureInfoField := func(key string, value string) func(map[string]json.RawMessage) {
+		return func(inner map[string]json.RawMessage) {
+			var featureInfo map[string]json.RawMessage
+			if err := json.Unmarshal(inner["feature_info"], &featureInfo); err != nil {
+				t.Fatal(err)
+			}
+			featureInfo[key] = json.RawMessage(value)
+			if inner["feature_info"], err = marshalJSON(featureInfo, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	base := digest(artifact)
+
+	tests := []struct {
+		name    string
+		edit    func(map[string]json.RawMessage)
+		covered bool
+	}{
+		{"known top-level field", func(inner map[string]json.RawMessage) { inner["validation_tolerance"] = json.RawMessage("0.5") }, true},
+		{"unknown top-level field", func(inner map[string]json.RawMessage) { inner["exported_by"] = json.RawMessage(`"ci"`) }, true},
+		{"known feature_info field", withFeatureInfoField("target_column", `"clicked"`), true},
+		// A documented limit: decoding drops unknown nested fields
+		{"unknown feature_info field", withFeatureInfoField("exported_by", `"ci"`), false},
+		{"integrity block", func(inner map[string]json.RawMessage) {
+			inner["integrity"] = json.RawMessage(`{"model_sha256": "00", "metadata_sha256": "00"}`)
+		}, false},
+	}
+	for _, tc := range tests {
+		changed := digest(editArtifact(t, artifact, tc.edit)) != base
+		if changed != tc.covered {
+			t.Errorf("%s: digest changed = %t, want %t", tc.name, changed, tc.covered)
+		}
+	}
+}
@@ -1,18 +1,85 @@
 package main
 
 import (
-	"encoding/base64"
+	"flag"
 	"fmt"
 	"log"
-	"math"
+	"os"
 	"strings"
 )
 
 func main() {
+	// Without a command name, run validation for backward compatibility
+	if len(os.Args) < 2 || strings.HasPrefix(os.Args[1], "-") {
+		exitOnParityFailure(runValidate(os.Args[1:]))
+		return
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "validate":
+		exitOnParityFailure(runValidate(args))
+	case "evaluate":
+		runEvaluate(args)
+	case "card":
+		runCard(args)
+	case "inspect":
+		runInspect(args)
+	case "uacheck":
+		runUACheck(args)
+	case "extract":
+		runExtract(args)
+	case "unpack":
+		runUnpack(args)
+	case "pack":
+		runPack(args)
+	case "sign":
+		runSign(args)
+	case "encrypt":
+		runEncrypt(args)
+	case "migrate":
+		runMigrate(args)
+	case "diff":
+		runDiff(args)
+	case "keygen":
+		runKeygen(args)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", command)
+		fmt.Fprintf(os.Stderr, "Usage: %s [validate|evaluate|inspect|card|uacheck|extract|unpack|pack|sign|encrypt|migrate|diff|keygen] [flags]\n", os.Args[0])
+		os.Exit(2)
+	}
+}
+
+// exitOnParityFailure exits with status 1 when validation did not pass,
+// so pipelines can gate on the exit code
+func exitOnParityFailure(passed bool) {
+	if !passed {
+		os.Exit(1)
+	}
+}
+
+// runValidate runs the model on its validation data and compares the
+// outputs with the Python predictions. It reports whether every prediction
+// matched within tolerance.
+func runValidate(args []string) bool {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	artifact := addArtifactFlags(flags)
+	encoding := addEncodingFlags(flags)
+	parityOptions := addParityFlags(flags)
+	sliceOptions := addSliceFlags(flags)
+	worst := flags.Int("worst", 5, "number of worst-offending samples to report")
+	jsonReportPath := flags.String("report-json", "", "write a JSON validation report to this path")
+	junitReportPath := flags.String("report-junit", "", "write a JUnit XML validation report to this path")
+	columnSelection := flags.String("columns", "all", "comma-separated feature columns for the results table, or all/none")
+	columnWidth := flags.Int("column-width", 12, "maximum width of each feature column in the results table")
+	diagnose := flags.Bool("diagnose", false, "dump the encoded input tensors per sample to stderr before the forward pass and compare them with the artifact's reference encodings")
+	dumpPath := flags.String("dump-encoded", "", "write the encoded input tensors per sample as JSON to this path")
+	flags.Parse(args)
+
 	fmt.Println("=== PyTorch Model Inference Demo ===")
 
 	// Load and parse the JSON file
-	modelData, torchData, err := loadModelData("Data/model.json")
+	modelData, torchData, err := artifact.load()
 	if err != nil {
 		log.Fatalf("Failed to load model data: %v", err)
 	}
@@ -25,23 +92,19 @@ func main() {
 	fmt.Printf("- Validation Samples: %d\n", len(torchData.ValidationData))
 	fmt.Printf("- Expected Predictions: %d\n", len(torchData.ValidationPredictions))
 
-	fmt.Printf("\nFeature Mappings:\n")
-	fmt.Printf("- Platforms: %d categories (indices 0-%d)\n", len(torchData.FeatureInfo.MissingValueHandling.LabelEncoders["platform"].Classes), len(torchData.FeatureInfo.MissingValueHandling.LabelEncoders["platform"].Classes)-1)
-	fmt.Printf("- Geos: %d categories (indices 0-%d)\n", len(torchData.FeatureInfo.MissingValueHandling.LabelEncoders["geo"].Classes), len(torchData.FeatureInfo.MissingValueHandling.LabelEncoders["geo"].Classes)-1)
-	fmt.Printf("- Placement Types: %d categories (indices 0-%d)\n", len(torchData.FeatureInfo.MissingValueHandling.LabelEncoders["placement_type"].Classes), len(torchData.FeatureInfo.MissingValueHandling.LabelEncoders["placement_type"].Classes)-1)
-	fmt.Printf("- Pub App Objects: %d categories (indices 0-%d)\n", len(torchData.FeatureInfo.MissingValueHandling.LabelEncoders["pub_app_object_id"].Classes), len(torchData.FeatureInfo.MissingValueHandling.LabelEncoders["pub_app_object_id"].Classes)-1)
+	printFeatureSummary(torchData.FeatureInfo)
 
-	// Decode the PyTorch model
-	modelBytes, err := base64.StdEncoding.DecodeString(torchData.TorchModel.Model)
+	tableColumns, err := selectTableColumns(torchData.FeatureInfo, *columnSelection)
 	if err != nil {
-		log.Fatalf("Failed to decode model: %v", err)
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	if *columnWidth < 1 {
+		log.Fatalf("Invalid flags: -column-width must be at least 1")
 	}
 
-	fmt.Printf("\nModel decoded: %d bytes\n", len(modelBytes))
-
-	// Load the PyTorch model directly from bytes using our custom bindings
+	// Load the PyTorch model directly from memory using our custom bindings
 	fmt.Printf("\nLoading PyTorch model from memory...\n")
-	model, err := loadTorchModuleFromBytes(modelBytes)
+	model, err := artifact.loadModel(torchData)
 	if err != nil {
 		log.Fatalf("Failed to load PyTorch model: %v", err)
 	}
@@ -51,7 +114,23 @@ func main() {
 
 	// Prepare input data from validation samples
 	fmt.Printf("\nPreparing validation data...\n")
-	numericalTensor, categoricalTensor, err := prepareValidationInput(torchData.ValidationData, torchData.FeatureInfo)
+	options, err := encoding.options(torchData.FeatureInfo)
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	options.Verbose = true
+
+	batch, err := encodeSamples(torchData.ValidationData, torchData.FeatureInfo, options)
+	if err != nil {
+		log.Fatalf("Failed to encode validation data: %v", err)
+	}
+	// Diagnose before the forward pass, which is most worth diagnosing when it fails
+	if *diagnose || *dumpPath != "" {
+		if err := runDiagnostics(torchData, batch, *dumpPath); err != nil {
+			log.Fatalf("Failed to run encoding diagnostics: %v", err)
+		}
+	}
+	numericalTensor, categoricalTensor, err := batch.toTensors()
 	if err != nil {
 		log.Fatalf("Failed to prepare input tensors: %v", err)
 	}
@@ -61,6 +140,11 @@ func main() {
 	fmt.Printf("Numerical tensor prepared with shape: [%d, %d]\n", len(torchData.ValidationData), len(torchData.FeatureInfo.FeatureNames["numerical"]))
 	fmt.Printf("Categorical tensor prepared with shape: [%d, %d]\n", len(torchData.ValidationData), len(torchData.FeatureInfo.FeatureNames["categorical"]))
 
+	if options.Canonicalizer != nil {
+		fmt.Printf("\nCategorical canonicalization:\n")
+		options.Canonicalizer.PrintSummary()
+	}
+
 	// Perform forward inference
 	fmt.Printf("\nPerforming forward inference...\n")
 	outputTensor, err := model.Forward(numericalTensor, categoricalTensor)
@@ -75,85 +159,282 @@ func main() {
 		log.Fatalf("Failed to extract predictions: %v", err)
 	}
 
-	// Display results and perform strict validation
+	// Compare with the Python predictions
+	checker, err := parityOptions.checker(torchData.ValidationTolerance)
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	parity, err := checker.Compare(predictions, torchData.ValidationPredictions)
+	if err != nil {
+		log.Fatalf("Failed to compare predictions: %v", err)
+	}
+
+	// Display results
 	fmt.Printf("\n=== Validation Results ===\n")
-	fmt.Printf("%-3s %-12s %-8s %-4s %-8s %-12s %-20s %-12s %-12s %-12s %-8s\n",
-		"#", "RTB_ID", "Platform", "Geo", "DNT", "OS_Ver", "Placement", "Predicted", "Expected", "Error", "Match")
-	fmt.Printf("%s\n", strings.Repeat("-", 140))
-
-	var totalSquaredError float64
-	var totalAbsError float64
-	var exactMatches int
-	var closeMatches int
-	tolerance := 1e-6 // Very small tolerance for floating point comparison
-
-	for i := 0; i < len(predictions) && i < len(torchData.ValidationPredictions); i++ {
-		predicted := predictions[i]
-		expected := torchData.ValidationPredictions[i]
-		error := predicted - expected
-		absError := math.Abs(error)
-		squaredError := error * error
-
-		totalSquaredError += squaredError
-		totalAbsError += absError
-
-		// Check for exact or near-exact matches
-		var matchStatus string
-		if absError == 0.0 {
-			matchStatus = "EXACT"
-			exactMatches++
-		} else if absError < tolerance {
-			matchStatus = "CLOSE"
-			closeMatches++
-		} else {
-			matchStatus = "DIFF"
-		}
+	printValidationTable(torchData.ValidationData, parity, tableColumns, *columnWidth)
+
+	// Score the Go predictions against the Python predictions, reading both
+	// on the same scale
+	scale, err := decideScoreScale(torchData.TaskType, torchData.TorchModel.Config, len(predictions)/len(torchData.ValidationData), predictions, torchData.ValidationPredictions)
+	if err != nil {
+		log.Fatalf("Failed to interpret model outputs: %v", err)
+	}
+	targets, err := referenceTargets(torchData.TaskType, scale, torchData.ValidationPredictions, len(torchData.ValidationData))
+	if err != nil {
+		log.Fatalf("Failed to derive metric targets: %v", err)
+	}
+	weights, err := sampleWeights(torchData.ValidationData, torchData.WeightColumn)
+	if err != nil {
+		log.Fatalf("Failed to read sample weights: %v", err)
+	}
+	columns, err := metricColumns("Go vs Python", torchData.TaskType, scale, predictions, targets, weights, torchData.WeightColumn)
+	if err != nil {
+		log.Fatalf("Failed to compute metrics: %v", err)
+	}
 
-		sample := torchData.ValidationData[i]
-		fmt.Printf("%-3d %-12s %-8s %-4s %-8s %-12s %-20s %-12.6f %-12.6f %-12.6f %-8s\n",
-			i+1,
-			truncateString(fmt.Sprintf("%v", sample["rtb_id"]), 12),
-			fmt.Sprintf("%v", sample["platform"]),
-			fmt.Sprintf("%v", sample["geo"]),
-			fmt.Sprintf("%v", sample["do_not_track"]),
-			fmt.Sprintf("%v", sample["major_os_version"]),
-			truncateString(fmt.Sprintf("%v", sample["placement_type"]), 20),
-			predicted,
-			expected,
-			error,
-			matchStatus)
-	}
-
-	// Calculate and display metrics
-	numSamples := float64(len(predictions))
-	mse := totalSquaredError / numSamples
-	rmse := math.Sqrt(mse)
-	mae := totalAbsError / numSamples
-
-	fmt.Printf("\n=== Performance Metrics ===\n")
-	fmt.Printf("MSE (Mean Squared Error): %.10f\n", mse)
-	fmt.Printf("RMSE (Root Mean Squared Error): %.10f\n", rmse)
-	fmt.Printf("MAE (Mean Absolute Error): %.10f\n", mae)
-	fmt.Printf("Number of samples: %.0f\n", numSamples)
+	fmt.Printf("\n=== Performance Metrics (%s) ===\n", torchData.TaskType)
+	printMetricsTable(columns)
+	fmt.Printf("Number of samples: %d\n", len(targets))
 
+	sliceSpecs, err := parseSliceSpecs(*sliceOptions.by, torchData.FeatureInfo)
+	if err != nil {
+		log.Fatalf("Invalid flags: %v", err)
+	}
+	for _, spec := range sliceSpecs {
+		slices, err := computeSlices(spec, torchData.ValidationData, torchData.TaskType, scale, predictions, targets, weights, parity)
+		if err != nil {
+			log.Fatalf("Failed to compute slices: %v", err)
+		}
+		sliceOptions.print("Go vs Python", spec, slices, torchData.TaskType, true)
+	}
+
+	numCompared := len(parity.Samples)
 	fmt.Printf("\n=== Validation Summary ===\n")
-	fmt.Printf("Exact matches: %d/%d (%.1f%%)\n", exactMatches, len(predictions), float64(exactMatches)/float64(len(predictions))*100)
-	fmt.Printf("Close matches (< %.0e): %d/%d (%.1f%%)\n", tolerance, closeMatches, len(predictions), float64(closeMatches)/float64(len(predictions))*100)
-	fmt.Printf("Different values: %d/%d (%.1f%%)\n", len(predictions)-exactMatches-closeMatches, len(predictions), float64(len(predictions)-exactMatches-closeMatches)/float64(len(predictions))*100)
+	fmt.Printf("Comparison: %s (tolerance %s)\n", parity.Mode, parity.ToleranceString())
+	fmt.Printf("Exact matches: %d/%d (%.1f%%)\n", parity.Exact, numCompared, float64(parity.Exact)/float64(numCompared)*100)
+	fmt.Printf("Close matches (<= %s): %d/%d (%.1f%%)\n", parity.ToleranceString(), parity.Close, numCompared, float64(parity.Close)/float64(numCompared)*100)
+	fmt.Printf("Different values: %d/%d (%.1f%%)\n", parity.Diff, numCompared, float64(parity.Diff)/float64(numCompared)*100)
+
+	if *worst > 0 && parity.Exact < numCompared {
+		fmt.Printf("\nWorst samples by %s distance:\n", parity.Mode)
+		for _, result := range parity.Worst(*worst) {
+			fmt.Printf("- #%d: predicted %.6f, expected %.6f, distance %.6g (%s)\n",
+				result.Index+1, result.Predicted, result.Expected, result.Distance, result.Status)
+		}
+	}
 
 	// Final validation check
-	if exactMatches == len(predictions) {
+	if parity.Exact == numCompared {
 		fmt.Printf("\n✅ SUCCESS: All predictions match exactly with Python validation outputs!\n")
-	} else if exactMatches+closeMatches == len(predictions) {
-		fmt.Printf("\n⚠️  CLOSE: All predictions are very close to Python validation outputs (within %.0e tolerance)\n", tolerance)
+	} else if parity.Matched() {
+		fmt.Printf("\n⚠️  CLOSE: All predictions are very close to Python validation outputs (within %s tolerance)\n", parity.ToleranceString())
 	} else {
-		fmt.Printf("\n❌ WARNING: %d predictions differ significantly from Python validation outputs\n", len(predictions)-exactMatches-closeMatches)
+		fmt.Printf("\n❌ WARNING: %d predictions differ significantly from Python validation outputs\n", parity.Diff)
 		fmt.Printf("This may indicate issues with:\n")
 		fmt.Printf("- Model loading or deserialization\n")
 		fmt.Printf("- Input preprocessing or feature encoding\n")
 		fmt.Printf("- Tensor shape or data type mismatches\n")
 		fmt.Printf("- Numerical precision differences between Python and Go\n")
+		if !*diagnose {
+			fmt.Printf("Rerun with -diagnose to compare the encoded inputs with the Python reference\n")
+		}
+	}
+
+	if *jsonReportPath != "" || *junitReportPath != "" {
+		report := buildValidationReport(*artifact.model, torchData, parity, columns)
+		if *jsonReportPath != "" {
+			if err := writeJSONReport(*jsonReportPath, report); err != nil {
+				log.Fatalf("Failed to write report: %v", err)
+			}
+			fmt.Printf("\nJSON report written to %s\n", *jsonReportPath)
+		}
+		if *junitReportPath != "" {
+			if err := writeJUnitReport(*junitReportPath, report); err != nil {
+				log.Fatalf("Failed to write report: %v", err)
+			}
+			fmt.Printf("JUnit report written to %s\n", *junitReportPath)
+		}
 	}
 
 	fmt.Printf("\n=== Inference completed! ===\n")
+	return parity.Matched()
+}
+
+// runUACheck checks the User-Agent parser against a corpus of known
+// User-Agent strings, using the vocabulary of the given model
+func runUACheck(args []string) {
+	flags := flag.NewFlagSet("uacheck", flag.ExitOnError)
+	artifact := addArtifactFlags(flags)
+	corpusPath := flags.String("corpus", "data/useragents.jsonl", "path to the User-Agent corpus (JSONL)")
+	flags.Parse(args)
+
+	_, torchData, err := artifact.load()
+	if err != nil {
+		log.Fatalf("Failed to load model data: %v", err)
+	}
+
+	cases, err := loadUserAgentCorpus(*corpusPath)
+	if err != nil {
+		log.Fatalf("Failed to load User-Agent corpus: %v", err)
+	}
+
+	parser := newUserAgentParser(torchData.FeatureInfo)
+	failures := 0
+	for i, c := range cases {
+		platform, osVersion := parser.Parse(c.UserAgent)
+		if platform != c.Platform || osVersion != c.MajorOSVersion {
+			failures++
+			fmt.Printf("FAIL case %d: got %s/%s, expected %s/%s\n  %s\n", i+1, platform, osVersion, c.Platform, c.MajorOSVersion, c.UserAgent)
+		}
+	}
+
+	fmt.Printf("%d/%d User-Agent cases passed\n", len(cases)-failures, len(cases))
+	if failures > 0 {
+		os.Exit(1)
+	}
+}
+
+// artifactFlags holds the artifact loading flags shared by commands
+type artifactFlags struct {
+	model       *string
+	force       *bool
+	trustedKeys *string
+	keys        *string
+}
+
+// addArtifactFlags registers the artifact loading flags
+func addArtifactFlags(flags *flag.FlagSet) *artifactFlags {
+	artifact := addLoadFlags(flags)
+	artifact.model = flags.String("model", "data/model.json", "path to the model JSON file, or a model directory or .pt file with sidecar metadata")
+	return artifact
+}
+
+// addLoadFlags registers the artifact loading flags other than -model, for
+// commands that load several artifacts
+func addLoadFlags(flags *flag.FlagSet) *artifactFlags {
+	return &artifactFlags{
+		force:       flags.Bool("force", false, "load the artifact even if it fails the consistency checks"),
+		trustedKeys: flags.String("trusted-keys", "", "JSON file of trusted ed25519 public keys; requires a valid artifact signature"),
+		keys:        flags.String("keys", "", "decryption key provider for encrypted artifacts: a keyfile path, file:PATH or env:PREFIX"),
+	}
+}
+
+// options returns the load options selected by the flags
+func (f *artifactFlags) options() (LoadOptions, error) {
+	options := LoadOptions{Force: *f.force}
+	if *f.trustedKeys != "" {
+		keys, err := loadTrustedKeys(*f.trustedKeys)
+		if err != nil {
+			return LoadOptions{}, err
+		}
+		options.TrustedKeys = keys
+	}
+	if *f.keys != "" {
+		provider, err := newKeyProvider(*f.keys)
+		if err != nil {
+			return LoadOptions{}, err
+		}
+		options.KeyProvider = provider
+	}
+	return options, nil
+}
+
+// load loads the artifact selected by the flags
+func (f *artifactFlags) load() (*ModelData, *TorchModelData, error) {
+	options, err := f.options()
+	if err != nil {
+		return nil, nil, err
+	}
+	return loadArtifact(*f.model, options)
+}
+
+// loadModel loads the artifact's TorchScript module
+func (f *artifactFlags) loadModel(torchData *TorchModelData) (*TorchModule, error) {
+	options, err := f.options()
+	if err != nil {
+		return nil, err
+	}
+	return loadTorchModel(torchData, options)
+}
+
+// encodingFlags holds the feature encoding flags shared by commands
+type encodingFlags struct {
+	conversion       *string
+	canonicalization *string
+	canonicalize     *bool
+}
+
+// addEncodingFlags registers the feature encoding flags
+func addEncodingFlags(flags *flag.FlagSet) *encodingFlags {
+	return &encodingFlags{
+		conversion:       flags.String("conversion", "lenient", "numerical conversion mode: lenient or strict"),
+		canonicalization: flags.String("canonicalize", "", "path to per-feature categorical canonicalization rules (JSON), merged into the defaults; enables canonicalization"),
+		canonicalize:     flags.Bool("canonicalize-defaults", false, "canonicalize categorical values with the default rules"),
+	}
+}
+
+// options builds the encoding options selected by the flags
+func (f *encodingFlags) options(featureInfo FeatureInfo) (EncodingOptions, error) {
+	conversionMode, err := parseConversionMode(*f.conversion)
+	if err != nil {
+		return EncodingOptions{}, err
+	}
+
+	options := EncodingOptions{Conversion: conversionMode}
+	if *f.canonicalize || *f.canonicalization != "" {
+		rules := defaultCanonicalizationRules(featureInfo)
+		if *f.canonicalization != "" {
+			rules, err = loadCanonicalizationRules(*f.canonicalization, featureInfo)
+			if err != nil {
+				return EncodingOptions{}, err
+			}
+		}
+		options.Canonicalizer = newCanonicalizer(rules)
+		fmt.Fprintf(os.Stderr, "NOTE: categorical canonicalization is active; values that are not encoder classes are rewritten before encoding\n")
+	}
+	return options, nil
+}
+
+// parityFlags holds the parity comparison flags shared by commands
+type parityFlags struct {
+	compare   *string
+	tolerance *float64
+}
+
+// addParityFlags registers the parity comparison flags
+func addParityFlags(flags *flag.FlagSet) *parityFlags {
+	return &parityFlags{
+		compare:   flags.String("compare", "absolute", "parity comparison mode: absolute, relative or ulp"),
+		tolerance: flags.Float64("tolerance", -1, "parity tolerance (default: the artifact's validation_tolerance, or 4 in ulp mode)"),
+	}
+}
+
+// checker builds the parity checker selected by the flags
+func (f *parityFlags) checker(artifactTolerance float64) (*ParityChecker, error) {
+	mode, err := parseComparisonMode(*f.compare)
+	if err != nil {
+		return nil, err
+	}
+	return newParityChecker(mode, *f.tolerance, artifactTolerance), nil
+}
+
+// sliceFlags holds the segmented evaluation flags shared by commands
+type sliceFlags struct {
+	by       *string
+	top      *int
+	minCount *int
+}
+
+// addSliceFlags registers the segmented evaluation flags
+func addSliceFlags(flags *flag.FlagSet) *sliceFlags {
+	return &sliceFlags{
+		by:       flags.String("slice", "", "comma-separated categorical features to slice by; use a:b for a cross of two features"),
+		top:      flags.Int("slice-top", 10, "number of worst slices to show per slicing"),
+		minCount: flags.Int("slice-min-count", 1, "minimum samples for a slice to be shown"),
+	}
+}
+
+// print prints the worst slices using the flag settings
+func (f *sliceFlags) print(title string, spec []string, slices []SliceStats, taskType string, withParity bool) {
+	printSlices(title, spec, slices, taskType, withParity, *f.top, *f.minCount)
 }
@@ -0,0 +1,557 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Task types, normalized from TorchModelData.TaskType
+const (
+	TaskRegression = "regression"
+	TaskBinary     = "binary"
+	TaskMulticlass = "multiclass"
+)
+
+// probabilityEpsilon clips probabilities away from 0 and 1 in log loss
+const probabilityEpsilon = 1e-15
+
+// percentageEpsilon floors the MAPE denominator, as numpy.finfo(float64).eps
+// does in scikit-learn
+const percentageEpsilon = 2.220446049250313e-16
+
+// ScoreScale says whether classification scores are logits or probabilities
+type ScoreScale string
+
+// Score scales; regression outputs have no scale
+const (
+	ScaleProbabilities ScoreScale = "probabilities"
+	ScaleLogits        ScoreScale = "logits"
+)
+
+// Metric is a single named evaluation metric
+type Metric struct {
+	Name  string
+	Label string
+	Value float64
+}
+
+// normalizeTaskType maps the task type names used by the exporter to
+// regression, binary or multiclass
+func normalizeTaskType(taskType string) (string, error) {
+	switch strings.ToLower(taskType) {
+	case "regression", "":
+		return TaskRegression, nil
+	case "binary", "binary_classification", "classification":
+		return TaskBinary, nil
+	case "multiclass", "multiclass_classification", "multi_class":
+		return TaskMulticlass, nil
+	default:
+		return "", fmt.Errorf("unsupported task type %q", taskType)
+	}
+}
+
+// computeMetrics computes the metrics suited to the task type.
+// predictions holds one value per sample for regression and binary tasks and
+// one score per class per sample (row-major) for multiclass. targets holds
+// one value per sample: the target for regression, a 0/1 label for binary and
+// a class index for multiclass. scale says how classification scores are
+// read; see decideScoreScale. weights holds one non-negative weight per
+// sample; nil weighs samples equally.
+func computeMetrics(taskType string, scale ScoreScale, predictions []float64, targets []float64, weights []float64) ([]Metric, error) {
+	task, err := normalizeTaskType(taskType)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets to evaluate")
+	}
+	weights, err = normalizeWeights(weights, len(targets))
+	if err != nil {
+		return nil, err
+	}
+
+	switch task {
+	case TaskBinary:
+		if len(predictions) != len(targets) {
+			return nil, fmt.Errorf("got %d predictions for %d targets", len(predictions), len(targets))
+		}
+		if err := checkLabels(targets, 2); err != nil {
+			return nil, err
+		}
+		return binaryMetrics(toProbabilities(predictions, scale), targets, weights), nil
+	case TaskMulticlass:
+		if len(predictions)%len(targets) != 0 || len(predictions) < 2*len(targets) {
+			return nil, fmt.Errorf("got %d scores for %d targets, expected one score per class", len(predictions), len(targets))
+		}
+		numClasses := len(predictions) / len(targets)
+		if err := checkLabels(targets, numClasses); err != nil {
+			return nil, err
+		}
+		return multiclassMetrics(toClassProbabilities(predictions, numClasses, scale), targets, weights, numClasses), nil
+	default:
+		if len(predictions) != len(targets) {
+			return nil, fmt.Errorf("got %d predictions for %d targets", len(predictions), len(targets))
+		}
+		for i, target := range targets {
+			if math.IsNaN(target) || math.IsInf(target, 0) {
+				return nil, fmt.Errorf("sample %d has invalid target %g", i+1, target)
+			}
+		}
+		return regressionMetrics(predictions, targets, weights), nil
+	}
+}
+
+// normalizeWeights checks per-sample weights, returning unit weights for nil
+func normalizeWeights(weights []float64, numSamples int) ([]float64, error) {
+	if weights == nil {
+		weights = make([]float64, numSamples)
+		for i := range weights {
+			weights[i] = 1
+		}
+		return weights, nil
+	}
+	if len(weights) != numSamples {
+		return nil, fmt.Errorf("got %d weights for %d samples", len(weights), numSamples)
+	}
+
+	var total float64
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("sample %d has invalid weight %g", i+1, w)
+		}
+		total += w
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("sample weights sum to zero")
+	}
+	return weights, nil
+}
+
+// checkLabels checks that classification labels are class indices below
+// numClasses; binary labels must be 0 or 1
+func checkLabels(labels []float64, numClasses int) error {
+	for i, label := range labels {
+		if label != math.Trunc(label) || label < 0 || label >= float64(numClasses) {
+			return fmt.Errorf("sample %d has invalid label %g (expected a class index from 0 to %d)", i+1, label, numClasses-1)
+		}
+	}
+	return nil
+}
+
+// sampleWeights reads per-sample weights from the weight column. It returns
+// nil when no weight column is configured or no sample carries it.
+func sampleWeights(samples []ValidationData, weightColumn string) ([]float64, error) {
+	if weightColumn == "" {
+		return nil, nil
+	}
+	present := 0
+	for _, sample := range samples {
+		if _, exists := sample[weightColumn]; exists {
+			present++
+		}
+	}
+	if present == 0 {
+		return nil, nil
+	}
+	return extractColumn(samples, weightColumn)
+}
+
+// decideScoreScale decides once whether an artifact's classification scores
+// are logits or probabilities, so every metric, slice and reference target
+// reads them the same way. An output_activation in the model config decides;
+// otherwise the scores are probabilities only if every score of every set
+// lies in [0, 1] and, for multiclass, every row sums to 1.
+func decideScoreScale(taskType string, config string, outputsPerSample int, scoreSets ...[]float64) (ScoreScale, error) {
+	task, err := normalizeTaskType(taskType)
+	if err != nil || task == TaskRegression {
+		return "", err
+	}
+
+	modelConfig, err := parseModelConfig(config)
+	if err != nil {
+		return "", err
+	}
+	if modelConfig.OutputActivation != "" {
+		return activationScoreScale(modelConfig.OutputActivation)
+	}
+
+	if outputsPerSample < 1 {
+		outputsPerSample = 1
+	}
+	for _, scores := range scoreSets {
+		for i, s := range scores {
+			if s < 0 || s > 1 {
+				return ScaleLogits, nil
+			}
+			if task == TaskMulticlass && i%outputsPerSample == 0 && i+outputsPerSample <= len(scores) {
+				var sum float64
+				for _, p := range scores[i : i+outputsPerSample] {
+					sum += p
+				}
+				if math.Abs(sum-1) > 1e-4 {
+					return ScaleLogits, nil
+				}
+			}
+		}
+	}
+	return ScaleProbabilities, nil
+}
+
+// activationScoreScale returns the scale of scores produced by an output
+// activation
+func activationScoreScale(activation string) (ScoreScale, error) {
+	switch strings.ToLower(activation) {
+	case "sigmoid", "softmax":
+		return ScaleProbabilities, nil
+	case "none", "linear", "identity":
+		return ScaleLogits, nil
+	default:
+		return "", fmt.Errorf("unknown output_activation %q (expected sigmoid, softmax or none)", activation)
+	}
+}
+
+// referenceTargets derives targets from reference model outputs, so Go
+// predictions can be scored against Python predictions: regression outputs
+// are used as-is, classification outputs become their predicted class
+func referenceTargets(taskType string, scale ScoreScale, reference []float64, numSamples int) ([]float64, error) {
+	task, err := normalizeTaskType(taskType)
+	if err != nil {
+		return nil, err
+	}
+
+	switch task {
+	case TaskBinary:
+		targets := make([]float64, len(reference))
+		for i, p := range toProbabilities(reference, scale) {
+			if p >= 0.5 {
+				targets[i] = 1
+			}
+		}
+		return targets, nil
+	case TaskMulticlass:
+		if numSamples == 0 || len(reference)%numSamples != 0 {
+			return nil, fmt.Errorf("cannot split %d reference scores into %d samples", len(reference), numSamples)
+		}
+		numClasses := len(reference) / numSamples
+		targets := make([]float64, numSamples)
+		for i := range targets {
+			targets[i] = float64(argmax(reference[i*numClasses : (i+1)*numClasses]))
+		}
+		return targets, nil
+	default:
+		return reference, nil
+	}
+}
+
+// regressionMetrics computes MSE, RMSE, MAE, R², MAPE and explained variance.
+// As in scikit-learn, MAPE divides by the absolute target floored at machine
+// epsilon, so a zero target with a nonzero error yields a huge percentage.
+func regressionMetrics(predictions []float64, targets []float64, weights []float64) []Metric {
+	var totalWeight, sumSquared, sumAbs, sumTarget, sumResidual, sumPercentage float64
+	for i, target := range targets {
+		w := weights[i]
+		residual := target - predictions[i]
+		totalWeight += w
+		sumSquared += w * residual * residual
+		sumAbs += w * math.Abs(residual)
+		sumTarget += w * target
+		sumResidual += w * residual
+		sumPercentage += w * math.Abs(residual) / math.Max(math.Abs(target), percentageEpsilon)
+	}
+
+	meanTarget := sumTarget / totalWeight
+	meanResidual := sumResidual / totalWeight
+	var targetVariance, residualVariance float64
+	for i, target := range targets {
+		residual := target - predictions[i]
+		targetVariance += weights[i] * (target - meanTarget) * (target - meanTarget)
+		residualVariance += weights[i] * (residual - meanResidual) * (residual - meanResidual)
+	}
+
+	mse := sumSquared / totalWeight
+
+	return []Metric{
+		{Name: "mse", Label: "MSE (Mean Squared Error)", Value: mse},
+		{Name: "rmse", Label: "RMSE (Root Mean Squared Error)", Value: math.Sqrt(mse)},
+		{Name: "mae", Label: "MAE (Mean Absolute Error)", Value: sumAbs / totalWeight},
+		{Name: "r2", Label: "R² (Coefficient of Determination)", Value: 1 - ratio(sumSquared, targetVariance)},
+		{Name: "mape", Label: "MAPE (Mean Absolute Percentage Error, %)", Value: sumPercentage / totalWeight * 100},
+		{Name: "explained_variance", Label: "Explained Variance", Value: 1 - ratio(residualVariance, targetVariance)},
+	}
+}
+
+// binaryMetrics computes log loss, ROC AUC, PR AUC, accuracy and Brier score
+func binaryMetrics(probabilities []float64, labels []float64, weights []float64) []Metric {
+	var totalWeight, logLoss, brier, correct float64
+	for i, label := range labels {
+		w := weights[i]
+		p := clipProbability(probabilities[i])
+		totalWeight += w
+		logLoss -= w * (label*math.Log(p) + (1-label)*math.Log(1-p))
+		brier += w * (probabilities[i] - label) * (probabilities[i] - label)
+		if (probabilities[i] >= 0.5) == (label >= 0.5) {
+			correct += w
+		}
+	}
+
+	return []Metric{
+		{Name: "log_loss", Label: "Log Loss", Value: logLoss / totalWeight},
+		{Name: "roc_auc", Label: "ROC AUC", Value: rocAUC(probabilities, labels, weights)},
+		{Name: "pr_auc", Label: "PR AUC (Average Precision)", Value: averagePrecision(probabilities, labels, weights)},
+		{Name: "accuracy", Label: "Accuracy", Value: correct / totalWeight},
+		{Name: "brier", Label: "Brier Score", Value: brier / totalWeight},
+	}
+}
+
+// multiclassMetrics computes log loss, accuracy, Brier score and
+// one-vs-rest macro ROC AUC
+func multiclassMetrics(probabilities []float64, labels []float64, weights []float64, numClasses int) []Metric {
+	var totalWeight, logLoss, brier, correct float64
+	for i, label := range labels {
+		w := weights[i]
+		row := probabilities[i*numClasses : (i+1)*numClasses]
+		class := int(label)
+		totalWeight += w
+		logLoss -= w * math.Log(clipProbability(row[class]))
+		for c, p := range row {
+			indicator := 0.0
+			if c == class {
+				indicator = 1
+			}
+			brier += w * (p - indicator) * (p - indicator)
+		}
+		if argmax(row) == class {
+			correct += w
+		}
+	}
+
+	// One-vs-rest AUC averaged over classes present in the labels
+	var aucSum float64
+	var aucCount int
+	for c := 0; c < numClasses; c++ {
+		scores := make([]float64, len(labels))
+		binary := make([]float64, len(labels))
+		for i, label := range labels {
+			scores[i] = probabilities[i*numClasses+c]
+			if int(label) == c {
+				binary[i] = 1
+			}
+		}
+		if auc := rocAUC(scores, binary, weights); !math.IsNaN(auc) {
+			aucSum += auc
+			aucCount++
+		}
+	}
+	macroAUC := math.NaN()
+	if aucCount > 0 {
+		macroAUC = aucSum / float64(aucCount)
+	}
+
+	return []Metric{
+		{Name: "log_loss", Label: "Log Loss", Value: logLoss / totalWeight},
+		{Name: "roc_auc", Label: "ROC AUC (one-vs-rest macro)", Value: macroAUC},
+		{Name: "accuracy", Label: "Accuracy", Value: correct / totalWeight},
+		{Name: "brier", Label: "Brier Score", Value: brier / totalWeight},
+	}
+}
+
+// rocAUC computes the area under the ROC curve as the weighted probability
+// that a positive scores above a negative, counting ties as half.
+// Returns NaN when only one class is present.
+func rocAUC(scores []float64, labels []float64, weights []float64) float64 {
+	order := sortedIndices(scores)
+
+	var positives, negatives, negativesBelow, area float64
+	for start := 0; start < len(order); {
+		end := start
+		var groupPositives, groupNegatives float64
+		for end < len(order) && scores[order[end]] == scores[order[start]] {
+			idx := order[end]
+			if labels[idx] >= 0.5 {
+				groupPositives += weights[idx]
+			} else {
+				groupNegatives += weights[idx]
+			}
+			end++
+		}
+		area += groupPositives * (negativesBelow + groupNegatives/2)
+		negativesBelow += groupNegatives
+		positives += groupPositives
+		negatives += groupNegatives
+		start = end
+	}
+
+	if positives == 0 || negatives == 0 {
+		return math.NaN()
+	}
+	return area / (positives * negatives)
+}
+
+// averagePrecision computes the area under the precision-recall curve as
+// average precision, treating tied scores as a single threshold.
+// Returns NaN when there are no positive labels.
+func averagePrecision(scores []float64, labels []float64, weights []float64) float64 {
+	order := sortedIndices(scores)
+
+	var totalPositives float64
+	for i, label := range labels {
+		if label >= 0.5 {
+			totalPositives += weights[i]
+		}
+	}
+	if totalPositives == 0 {
+		return math.NaN()
+	}
+
+	var truePositives, seen, previousRecall, ap float64
+	// Walk thresholds from the highest score down
+	for end := len(order); end > 0; {
+		start := end - 1
+		for start > 0 && scores[order[start-1]] == scores[order[end-1]] {
+			start--
+		}
+		for _, idx := range order[start:end] {
+			seen += weights[idx]
+			if labels[idx] >= 0.5 {
+				truePositives += weights[idx]
+			}
+		}
+		if seen > 0 {
+			recall := truePositives / totalPositives
+			ap += (recall - previousRecall) * (truePositives / seen)
+			previousRecall = recall
+		}
+		end = start
+	}
+	return ap
+}
+
+// sortedIndices returns the indices of values in ascending order of value
+func sortedIndices(values []float64) []int {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return values[order[i]] < values[order[j]]
+	})
+	return order
+}
+
+// toProbabilities passes binary logits through a sigmoid
+func toProbabilities(scores []float64, scale ScoreScale) []float64 {
+	if scale != ScaleLogits {
+		return scores
+	}
+	probabilities := make([]float64, len(scores))
+	for i, logit := range scores {
+		probabilities[i] = 1 / (1 + math.Exp(-logit))
+	}
+	return probabilities
+}
+
+// toClassProbabilities applies a softmax to each row of multiclass logits
+func toClassProbabilities(scores []float64, numClasses int, scale ScoreScale) []float64 {
+	if scale != ScaleLogits {
+		return scores
+	}
+
+	probabilities := make([]float64, len(scores))
+	for i := 0; i < len(scores); i += numClasses {
+		row := scores[i : i+numClasses]
+		maxScore := row[argmax(row)]
+		var sum float64
+		for c, s := range row {
+			probabilities[i+c] = math.Exp(s - maxScore)
+			sum += probabilities[i+c]
+		}
+		for c := range row {
+			probabilities[i+c] /= sum
+		}
+	}
+	return probabilities
+}
+
+// argmax returns the index of the largest value
+func argmax(values []float64) int {
+	best := 0
+	for i, v := range values {
+		if v > values[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// clipProbability keeps a probability inside (0, 1) for log loss
+func clipProbability(p float64) float64 {
+	return math.Min(math.Max(p, probabilityEpsilon), 1-probabilityEpsilon)
+}
+
+// ratio divides, returning 0 for 0/0 and NaN for x/0
+func ratio(numerator float64, denominator float64) float64 {
+	if denominator == 0 {
+		if numerator == 0 {
+			return 0
+		}
+		return math.NaN()
+	}
+	return numerator / denominator
+}
+
+// metricColumns computes metrics as table columns: weighted by the weight
+// column first when weights are given, then unweighted
+func metricColumns(title string, taskType string, scale ScoreScale, predictions []float64, targets []float64, weights []float64, weightColumn string) ([]MetricColumn, error) {
+	var columns []MetricColumn
+	if weights != nil {
+		weighted, err := computeMetrics(taskType, scale, predictions, targets, weights)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, MetricColumn{Title: fmt.Sprintf("%s (by %s)", title, weightColumn), Metrics: weighted})
+	}
+
+	unweighted, err := computeMetrics(taskType, scale, predictions, targets, nil)
+	if err != nil {
+		return nil, err
+	}
+	columns = append(columns, MetricColumn{Title: fmt.Sprintf("%s (unweighted)", title), Metrics: unweighted})
+	return columns, nil
+}
+
+// MetricColumn is a titled set of metrics shown as one table column
+type MetricColumn struct {
+	Title   string
+	Metrics []Metric
+}
+
+// printMetricsTable prints metric sets as columns of one table, matching
+// rows by metric name. Rows follow the order of the first column.
+func printMetricsTable(columns []MetricColumn) {
+	if len(columns) == 0 {
+		return
+	}
+
+	fmt.Printf("%-42s", "Metric")
+	for _, column := range columns {
+		fmt.Printf(" %-28s", column.Title)
+	}
+	fmt.Printf("\n%s\n", strings.Repeat("-", 42+29*len(columns)))
+
+	for _, metric := range columns[0].Metrics {
+		fmt.Printf("%-42s", metric.Label)
+		for _, column := range columns {
+			value := "n/a"
+			for _, m := range column.Metrics {
+				if m.Name == metric.Name {
+					value = fmt.Sprintf("%.10f", m.Value)
+					break
+				}
+			}
+			fmt.Printf(" %-28s", value)
+		}
+		fmt.Printf("\n")
+	}
+}
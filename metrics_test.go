@@ -0,0 +1,311 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// Expected values follow scikit-learn's roc_auc_score,
+// average_precision_score, log_loss and brier_score_loss with sample_weight
+var binaryMetricCases = []struct {
+	name    string
+	labels  []float64
+	scores  []float64
+	weights []float64
+	rocAUC  float64
+	avgPrec float64
+	logLoss float64
+	brier   float64
+}{
+	{
+		name:    "sklearn docs example",
+		labels:  []float64{0, 0, 1, 1},
+		scores:  []float64{0.1, 0.4, 0.35, 0.8},
+		weights: []float64{1, 1, 1, 1},
+		rocAUC:  0.75,
+		avgPrec: 0.8333333333333333,
+		logLoss: 0.47228795380917615,
+		brier:   0.158125,
+	},
+	{
+		name:    "weighted with tied scores",
+		labels:  []float64{0, 1, 0, 1, 1},
+		scores:  []float64{0.5, 0.5, 0.2, 0.9, 0.2},
+		weights: []float64{1, 2, 1, 0.5, 3},
+		rocAUC:  0.5,
+		avgPrec: 0.7506493506493506,
+		logLoss: 0.9578105450833678,
+		brier:   0.362,
+	},
+	{
+		name:    "weighted",
+		labels:  []float64{1, 0, 1, 0, 0, 1, 1, 0},
+		scores:  []float64{0.9, 0.3, 0.6, 0.6, 0.1, 0.45, 0.8, 0.7},
+		weights: []float64{0.5, 2, 1, 1, 3, 1.5, 1, 0.25},
+		rocAUC:  0.895,
+		avgPrec: 0.8273809523809523,
+		logLoss: 0.41279281415047353,
+		brier:   0.13182926829268296,
+	},
+}
+
+func TestRocAUC(t *testing.T) {
+	for _, tc := range binaryMetricCases {
+		if got := rocAUC(tc.scores, tc.labels, tc.weights); !closeTo(got, tc.rocAUC) {
+			t.Errorf("%s: rocAUC = %.15g, want %.15g", tc.name, got, tc.rocAUC)
+		}
+	}
+}
+
+func TestRocAUCSingleClass(t *testing.T) {
+	if got := rocAUC([]float64{0.2, 0.7}, []float64{1, 1}, []float64{1, 1}); !math.IsNaN(got) {
+		t.Errorf("rocAUC with one class = %g, want NaN", got)
+	}
+}
+
+func TestAveragePrecision(t *testing.T) {
+	for _, tc := range binaryMetricCases {
+		if got := averagePrecision(tc.scores, tc.labels, tc.weights); !closeTo(got, tc.avgPrec) {
+			t.Errorf("%s: averagePrecision = %.15g, want %.15g", tc.name, got, tc.avgPrec)
+		}
+	}
+}
+
+func TestBinaryMetricsWeighted(t *testing.T) {
+	for _, tc := range binaryMetricCases {
+		metrics, err := computeMetrics(TaskBinary, ScaleProbabilities, tc.scores, tc.labels, tc.weights)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		for name, want := range map[string]float64{"log_loss": tc.logLoss, "brier": tc.brier, "roc_auc": tc.rocAUC, "pr_auc": tc.avgPrec} {
+			if got := metricValue(metrics, name); !closeTo(got, want) {
+				t.Errorf("%s: %s = %.15g, want %.15g", tc.name, name, got, want)
+			}
+		}
+	}
+}
+
+func TestMulticlassMetricsWeighted(t *testing.T) {
+	probabilities := []float64{
+		0.7, 0.2, 0.1,
+		0.1, 0.8, 0.1,
+		0.3, 0.3, 0.4,
+		0.25, 0.5, 0.25,
+	}
+	labels := []float64{0, 1, 2, 0}
+	weights := []float64{1, 2, 0.5, 1}
+
+	metrics, err := computeMetrics(TaskMulticlass, ScaleProbabilities, probabilities, labels, weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{
+		"log_loss": 0.5883115052498044,
+		// brier_score_loss of multiclass targets sums over classes
+		"brier":    0.31222222222222223,
+		"accuracy": 3.5 / 4.5,
+	}
+	for name, value := range want {
+		if got := metricValue(metrics, name); !closeTo(got, value) {
+			t.Errorf("%s = %.15g, want %.15g", name, got, value)
+		}
+	}
+}
+
+// Expected values follow scikit-learn's mean_squared_error, r2_score,
+// explained_variance_score and mean_absolute_percentage_error (times 100)
+// with sample_weight
+func TestRegressionMetricsWeighted(t *testing.T) {
+	tests := []struct {
+		name        string
+		targets     []float64
+		predictions []float64
+		weights     []float64
+		want        map[string]float64
+	}{
+		{
+			name:        "sklearn docs example",
+			targets:     []float64{3, -0.5, 2, 7},
+			predictions: []float64{2.5, 0, 2, 8},
+			weights:     []float64{1, 1, 1, 1},
+			want:        map[string]float64{"mse": 0.375, "r2": 0.9486081370449679, "explained_variance": 0.9571734475374732, "mape": 32.73809523809524},
+		},
+		{
+			name:        "weighted",
+			targets:     []float64{1.5, 2, 0.5, 4, 3},
+			predictions: []float64{1, 2.5, 0.75, 3.5, 2},
+			weights:     []float64{1, 2, 0.5, 3, 1.5},
+			want:        map[string]float64{"mse": 0.37890625, "r2": 0.6966379984362783, "explained_variance": 0.7672009382329945, "mape": 24.479166666666666},
+		},
+		{
+			// A zero target divides by machine epsilon instead of being skipped
+			name:        "zero target",
+			targets:     []float64{0, 2, 4},
+			predictions: []float64{0.5, 2, 3},
+			weights:     []float64{1, 1, 2},
+			want:        map[string]float64{"mse": 0.5625, "r2": 0.7954545454545454, "explained_variance": 0.8465909090909091, "mape": 56294995342131210},
+		},
+	}
+	for _, tc := range tests {
+		metrics, err := computeMetrics(TaskRegression, "", tc.predictions, tc.targets, tc.weights)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		for name, want := range tc.want {
+			if got := metricValue(metrics, name); !closeTo(got, want) {
+				t.Errorf("%s: %s = %.15g, want %.15g", tc.name, name, got, want)
+			}
+		}
+	}
+}
+
+func TestComputeMetricsDispatch(t *testing.T) {
+	tests := []struct {
+		taskType string
+		scale    ScoreScale
+		scores   []float64
+		targets  []float64
+		names    []string
+		logLoss  float64
+	}{
+		{
+			taskType: "regression",
+			scores:   []float64{1, 2, 3},
+			targets:  []float64{1, 2, 4},
+			names:    []string{"mse", "rmse", "mae", "r2", "mape", "explained_variance"},
+		},
+		{
+			taskType: "binary_classification",
+			scale:    ScaleLogits,
+			scores:   []float64{2, -1, 0.5, -3},
+			targets:  []float64{1, 0, 0, 0},
+			names:    []string{"log_loss", "roc_auc", "pr_auc", "accuracy", "brier"},
+			logLoss:  0.36571350857876106,
+		},
+		{
+			taskType: "multiclass",
+			scale:    ScaleLogits,
+			scores:   []float64{2, 0, 0, 0, 2, 0},
+			targets:  []float64{0, 1},
+			names:    []string{"log_loss", "roc_auc", "accuracy", "brier"},
+			logLoss:  -math.Log(math.Exp(2) / (math.Exp(2) + 2)),
+		},
+	}
+	for _, tc := range tests {
+		metrics, err := computeMetrics(tc.taskType, tc.scale, tc.scores, tc.targets, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.taskType, err)
+		}
+		if len(metrics) != len(tc.names) {
+			t.Fatalf("%s: got %d metrics, want %d", tc.taskType, len(metrics), len(tc.names))
+		}
+		for i, name := range tc.names {
+			if metrics[i].Name != name {
+				t.Errorf("%s: metric %d is %s, want %s", tc.taskType, i, metrics[i].Name, name)
+			}
+		}
+		if tc.logLoss != 0 {
+			if got := metricValue(metrics, "log_loss"); !closeTo(got, tc.logLoss) {
+				t.Errorf("%s: log_loss = %.15g, want %.15g", tc.taskType, got, tc.logLoss)
+			}
+		}
+	}
+
+	if _, err := computeMetrics("ranking", "", []float64{1}, []float64{1}, nil); err == nil {
+		t.Errorf("unsupported task type was accepted")
+	}
+}
+
+func TestComputeMetricsRejectsInvalidLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		taskType string
+		scores   []float64
+		targets  []float64
+		want     string
+	}{
+		{"binary label 2", TaskBinary, []float64{0.2, 0.7}, []float64{0, 2}, "sample 2"},
+		{"binary label 0.5", TaskBinary, []float64{0.2, 0.7}, []float64{0.5, 1}, "sample 1"},
+		{"negative binary label", TaskBinary, []float64{0.2, 0.7}, []float64{1, -1}, "sample 2"},
+		{"fractional multiclass label", TaskMulticlass, []float64{0.5, 0.5, 0.5, 0.5}, []float64{0, 1.5}, "sample 2"},
+		{"out-of-range multiclass label", TaskMulticlass, []float64{0.5, 0.5, 0.5, 0.5}, []float64{2, 1}, "sample 1"},
+		{"NaN multiclass label", TaskMulticlass, []float64{0.5, 0.5, 0.5, 0.5}, []float64{0, math.NaN()}, "sample 2"},
+		{"NaN regression target", TaskRegression, []float64{1, 2}, []float64{1, math.NaN()}, "sample 2"},
+	}
+	for _, tc := range tests {
+		_, err := computeMetrics(tc.taskType, ScaleProbabilities, tc.scores, tc.targets, nil)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want one naming %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestDecideScoreScale(t *testing.T) {
+	tests := []struct {
+		name      string
+		taskType  string
+		config    string
+		perSample int
+		scoreSets [][]float64
+		want      ScoreScale
+	}{
+		{"regression has no scale", "regression", "", 1, [][]float64{{-3, 7}}, ""},
+		{"binary probabilities", "binary", "", 1, [][]float64{{0.1, 0.9}, {0.2, 0.8}}, ScaleProbabilities},
+		// One logit anywhere makes every set logits, so sets and slices agree
+		{"binary logits in one set", "binary", "", 1, [][]float64{{0.1, 0.9}, {0.2, 1.5}}, ScaleLogits},
+		{"multiclass distributions", "multiclass", "", 2, [][]float64{{0.3, 0.7, 0.5, 0.5}}, ScaleProbabilities},
+		{"multiclass rows not summing to 1", "multiclass", "", 2, [][]float64{{0.3, 0.3, 0.5, 0.5}}, ScaleLogits},
+		{"config sigmoid", "binary", `{"output_activation": "sigmoid"}`, 1, [][]float64{{0.1, 0.9}}, ScaleProbabilities},
+		{"config none", "binary", `{"output_activation": "none"}`, 1, [][]float64{{0.1, 0.9}}, ScaleLogits},
+	}
+	for _, tc := range tests {
+		got, err := decideScoreScale(tc.taskType, tc.config, tc.perSample, tc.scoreSets...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: scale = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := decideScoreScale("binary", `{"output_activation": "tanh"}`, 1); err == nil {
+		t.Errorf("unknown output_activation was accepted")
+	}
+}
+
+func TestComputeSlicesSharesScale(t *testing.T) {
+	samples := []ValidationData{{"geo": "US"}, {"geo": "US"}, {"geo": "DE"}, {"geo": "DE"}}
+	// The DE scores alone look like probabilities, but the run reads logits
+	scores := []float64{2, -2, 0.9, 0.1}
+	targets := []float64{1, 0, 1, 0}
+
+	slices, err := computeSlices([]string{"geo"}, samples, TaskBinary, ScaleLogits, scores, targets, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, slice := range slices {
+		if slice.Key != "geo=DE" {
+			continue
+		}
+		want := -(math.Log(1/(1+math.Exp(-0.9))) + math.Log(1-1/(1+math.Exp(-0.1)))) / 2
+		if got := metricValue(slice.Metrics, "log_loss"); !closeTo(got, want) {
+			t.Errorf("geo=DE log_loss = %.15g, want %.15g", got, want)
+		}
+	}
+}
+
+// metricValue returns the value of the named metric, or NaN
+func metricValue(metrics []Metric, name string) float64 {
+	for _, metric := range metrics {
+		if metric.Name == name {
+			return metric.Value
+		}
+	}
+	return math.NaN()
+}
+
+// closeTo compares floats to 1e-12 relative tolerance
+func closeTo(got float64, want float64) bool {
+	return math.Abs(got-want) <= 1e-12*math.Max(1, math.Abs(want))
+}
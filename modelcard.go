@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Model card output formats
+const (
+	CardFormatHTML     = "html"
+	CardFormatMarkdown = "markdown"
+)
+
+// ModelCard is the content of a human-readable model report
+type ModelCard struct {
+	Title             string
+	Model             string
+	Interval          int
+	TaskType          string
+	Hyperparameters   []CardField
+	Architecture      []CardField
+	Features          []CardFeature
+	TrainLosses       []float64
+	TotalTrainingTime float64
+	// Parity is nil when the model was not run on its validation data
+	Parity   *ValidationReport
+	Problems []string
+}
+
+// CardField is a named value shown in a model card table
+type CardField struct {
+	Name  string
+	Value string
+}
+
+// CardFeature is one row of the feature schema
+type CardFeature struct {
+	Name string
+	Kind string
+	// Vocabulary is the vocabulary size of a categorical feature
+	Vocabulary int
+	Transforms string
+}
+
+// runCard writes a self-contained HTML or Markdown model card for an
+// artifact, running the model on its validation data for the parity section
+func runCard(args []string) {
+	flags := flag.NewFlagSet("card", flag.ExitOnError)
+	artifact := addArtifactFlags(flags)
+	encoding := addEncodingFlags(flags)
+	parityOptions := addParityFlags(flags)
+	outPath := flags.String("out", "", "path of the model card; .md writes Markdown, anything else HTML")
+	format := flags.String("format", "", "model card format: html or markdown (default: from the -out extension)")
+	withParity := flags.Bool("parity", true, "run the model on its validation data and include the Go-vs-Python parity results")
+	flags.Parse(args)
+
+	if *outPath == "" {
+		log.Fatalf("Invalid flags: -out is required")
+	}
+	if *format == "" {
+		*format = CardFormatHTML
+		if ext := strings.ToLower(filepath.Ext(*outPath)); ext == ".md" || ext == ".markdown" {
+			*format = CardFormatMarkdown
+		}
+	}
+	if *format != CardFormatHTML && *format != CardFormatMarkdown {
+		log.Fatalf("Invalid flags: unknown format %q (expected html or markdown)", *format)
+	}
+
+	modelData, torchData, err := artifact.load()
+	if err != nil {
+		log.Fatalf("Failed to load model data: %v", err)
+	}
+
+	var parity *ValidationReport
+	if *withParity {
+		parity, err = cardParity(artifact, encoding, parityOptions, torchData)
+		if err != nil {
+			log.Fatalf("Failed to compute parity results: %v", err)
+		}
+	}
+	card := buildModelCard(*artifact.model, modelData, torchData, parity)
+
+	var out bytes.Buffer
+	if *format == CardFormatMarkdown {
+		// Markdown renderers drop data URIs, so the loss curve is a sidecar file
+		lossCurveLink := ""
+		if svg := lossCurveSVG(card.TrainLosses); svg != "" {
+			lossCurvePath := strings.TrimSuffix(*outPath, filepath.Ext(*outPath)) + "-loss.svg"
+			if err := os.WriteFile(lossCurvePath, []byte(svg), 0644); err != nil {
+				log.Fatalf("Failed to write loss curve: %v", err)
+			}
+			fmt.Printf("Loss curve written to %s\n", lossCurvePath)
+			lossCurveLink = url.PathEscape(filepath.Base(lossCurvePath))
+		}
+		err = writeModelCardMarkdown(&out, card, lossCurveLink)
+	} else {
+		err = writeModelCardHTML(&out, card)
+	}
+	if err != nil {
+		log.Fatalf("Failed to render model card: %v", err)
+	}
+	if err := os.WriteFile(*outPath, out.Bytes(), 0644); err != nil {
+		log.Fatalf("Failed to write model card: %v", err)
+	}
+	fmt.Printf("Model card written to %s\n", *outPath)
+}
+
+// cardParity runs the model on its validation data and compares the outputs
+// with the Python predictions
+func cardParity(artifact *artifactFlags, encoding *encodingFlags, parityOptions *parityFlags, torchData *TorchModelData) (*ValidationReport, error) {
+	if len(torchData.ValidationData) == 0 {
+		return nil, fmt.Errorf("the artifact has no validation data; rerun with -parity=false")
+	}
+	model, err := artifact.loadModel(torchData)
+	if err != nil {
+		return nil, err
+	}
+	defer model.Free()
+
+	options, err := encoding.options(torchData.FeatureInfo)
+	if err != nil {
+		return nil, err
+	}
+	predictions, err := predictSamples(model, torchData.ValidationData, torchData.FeatureInfo, options, 0)
+	if err != nil {
+		return nil, err
+	}
+	checker, err := parityOptions.checker(torchData.ValidationTolerance)
+	if err != nil {
+		return nil, err
+	}
+	parity, err := checker.Compare(predictions, torchData.ValidationPredictions)
+	if err != nil {
+		return nil, err
+	}
+
+	scale, err := decideScoreScale(torchData.TaskType, torchData.TorchModel.Config, len(predictions)/len(torchData.ValidationData), predictions, torchData.ValidationPredictions)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := referenceTargets(torchData.TaskType, scale, torchData.ValidationPredictions, len(torchData.ValidationData))
+	if err != nil {
+		return nil, err
+	}
+	weights, err := sampleWeights(torchData.ValidationData, torchData.WeightColumn)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := metricColumns("Go vs Python", torchData.TaskType, scale, predictions, targets, weights, torchData.WeightColumn)
+	if err != nil {
+		return nil, err
+	}
+	return buildValidationReport(*artifact.model, torchData, parity, columns), nil
+}
+
+// buildModelCard collects the model card content of an artifact
+func buildModelCard(modelPath string, modelData *ModelData, torchData *TorchModelData, parity *ValidationReport) *ModelCard {
+	name := strings.TrimSuffix(filepath.Base(modelPath), filepath.Ext(modelPath))
+	card := &ModelCard{
+		Title:             "Model Card: " + name,
+		Model:             modelPath,
+		Interval:          modelData.Interval,
+		TaskType:          torchData.TaskType,
+		TrainLosses:       torchData.TrainingHistory.TrainLosses,
+		TotalTrainingTime: torchData.TrainingHistory.TotalTrainingTime,
+		Parity:            parity,
+		Problems:          checkArtifact(torchData),
+		Hyperparameters: []CardField{
+			{"Learning Rate", fmt.Sprintf("%g", torchData.LearningRate)},
+			{"Weight Decay", fmt.Sprintf("%g", torchData.WeightDecay)},
+			{"Epochs", fmt.Sprintf("%d", torchData.Epochs)},
+			{"Batch Size", fmt.Sprintf("%d", torchData.BatchSize)},
+		},
+	}
+
+	if config, err := parseModelConfig(torchData.TorchModel.Config); err == nil {
+		if config.Has("categorical_vocab_sizes") {
+			card.Architecture = append(card.Architecture, CardField{"Embedding Tables", fmt.Sprintf("%d", len(config.CategoricalVocabSizes))})
+		}
+		if config.Has("embedding_dim") {
+			card.Architecture = append(card.Architecture, CardField{"Embedding Dim", fmt.Sprintf("%d", config.EmbeddingDim)})
+		}
+		if config.Has("hidden_dims") {
+			card.Architecture = append(card.Architecture, CardField{"Hidden Layers", fmt.Sprintf("%v", config.HiddenDims)})
+		}
+		if config.Has("dropout_rate") {
+			card.Architecture = append(card.Architecture, CardField{"Dropout Rate", fmt.Sprintf("%g", config.DropoutRate)})
+		}
+		if config.Has("num_classes") {
+			card.Architecture = append(card.Architecture, CardField{"Classes", fmt.Sprintf("%d", config.NumClasses)})
+		}
+	}
+
+	featureInfo := torchData.FeatureInfo
+	for _, featureName := range featureInfo.FeatureNames["numerical"] {
+		steps := make([]string, 0, len(featureInfo.Transforms[featureName]))
+		for _, transform := range featureInfo.Transforms[featureName] {
+			steps = append(steps, transform.Type)
+		}
+		card.Features = append(card.Features, CardFeature{Name: featureName, Kind: "numerical", Transforms: strings.Join(steps, " → ")})
+	}
+	for _, featureName := range featureInfo.FeatureNames["categorical"] {
+		card.Features = append(card.Features, CardFeature{Name: featureName, Kind: "categorical", Vocabulary: featureInfo.CategoricalVocabSizes[featureName]})
+	}
+	return card
+}
+
+// Loss curve dimensions in SVG user units
+const (
+	lossCurveWidth   = 640
+	lossCurveHeight  = 240
+	lossCurveMarginX = 70
+	lossCurveMarginY = 24
+)
+
+// lossCurveSVG draws the per-epoch training losses as a standalone SVG line
+// chart. It returns an empty string when there are no losses.
+func lossCurveSVG(losses []float64) string {
+	var finite []float64
+	for _, loss := range losses {
+		if !math.IsNaN(loss) && !math.IsInf(loss, 0) {
+			finite = append(finite, loss)
+		}
+	}
+	if len(finite) == 0 {
+		return ""
+	}
+	low, high := finite[0], finite[0]
+	for _, loss := range finite {
+		low, high = math.Min(low, loss), math.Max(high, loss)
+	}
+	if high == low {
+		low, high = low-0.5, high+0.5
+	}
+
+	plotWidth := float64(lossCurveWidth - 2*lossCurveMarginX)
+	plotHeight := float64(lossCurveHeight - 2*lossCurveMarginY)
+	x := func(epoch int) float64 {
+		if len(losses) == 1 {
+			return lossCurveMarginX + plotWidth/2
+		}
+		return lossCurveMarginX + plotWidth*float64(epoch)/float64(len(losses)-1)
+	}
+	y := func(loss float64) float64 {
+		return lossCurveMarginY + plotHeight*(high-loss)/(high-low)
+	}
+
+	var points []string
+	var lastX, lastY float64
+	for epoch, loss := range losses {
+		if math.IsNaN(loss) || math.IsInf(loss, 0) {
+			continue
+		}
+		lastX, lastY = x(epoch), y(loss)
+		points = append(points, fmt.Sprintf("%.1f,%.1f", lastX, lastY))
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="11">`, lossCurveWidth, lossCurveHeight, lossCurveWidth, lossCurveHeight)
+	svg.WriteString(`<rect width="100%" height="100%" fill="white"/>`)
+	fmt.Fprintf(&svg, `<path d="M%d %d V%d H%d" fill="none" stroke="#888"/>`, lossCurveMarginX, lossCurveMarginY, lossCurveHeight-lossCurveMarginY, lossCurveWidth-lossCurveMarginX)
+	fmt.Fprintf(&svg, `<polyline points="%s" fill="none" stroke="#1f77b4" stroke-width="2"/>`, strings.Join(points, " "))
+	if len(points) == 1 {
+		fmt.Fprintf(&svg, `<circle cx="%.1f" cy="%.1f" r="3" fill="#1f77b4"/>`, lastX, lastY)
+	}
+	fmt.Fprintf(&svg, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%.4g</text>`, lossCurveMarginX-6, y(high), high)
+	fmt.Fprintf(&svg, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%.4g</text>`, lossCurveMarginX-6, y(low), low)
+	fmt.Fprintf(&svg, `<text x="%.1f" y="%d" text-anchor="middle">1</text>`, x(0), lossCurveHeight-lossCurveMarginY+14)
+	if len(losses) > 1 {
+		fmt.Fprintf(&svg, `<text x="%.1f" y="%d" text-anchor="middle">%d</text>`, x(len(losses)-1), lossCurveHeight-lossCurveMarginY+14, len(losses))
+	}
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle">epoch</text>`, lossCurveWidth/2, lossCurveHeight-4)
+	fmt.Fprintf(&svg, `<text x="%d" y="14" text-anchor="middle">training loss</text>`, lossCurveWidth/2)
+	svg.WriteString(`</svg>`)
+	return svg.String()
+}
+
+// lossSummary describes the first, best and final finite training losses
+func lossSummary(losses []float64) string {
+	first, best, final, skipped := -1, -1, -1, 0
+	for epoch, loss := range losses {
+		if math.IsNaN(loss) || math.IsInf(loss, 0) {
+			skipped++
+			continue
+		}
+		if first < 0 {
+			first = epoch
+		}
+		if best < 0 || loss < losses[best] {
+			best = epoch
+		}
+		final = epoch
+	}
+	if first < 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("%d epochs: first %.6g, best %.6g (epoch %d), final %.6g", len(losses), losses[first], losses[best], best+1, losses[final])
+	if skipped > 0 {
+		summary += fmt.Sprintf(" (%d non-finite skipped)", skipped)
+	}
+	return summary
+}
+
+// formatDuration formats a training time in seconds
+func formatDuration(seconds float64) string {
+	if seconds <= 0 {
+		return "unknown"
+	}
+	if seconds < 60 {
+		return fmt.Sprintf("%.1fs", seconds)
+	}
+	hours, minutes := int(seconds)/3600, int(seconds)%3600/60
+	if hours == 0 {
+		return fmt.Sprintf("%dm %02ds (%.0fs)", minutes, int(seconds)%60, seconds)
+	}
+	return fmt.Sprintf("%dh %02dm (%.0fs)", hours, minutes, seconds)
+}
+
+// formatReportMetric formats a report metric value; undefined values are n/a
+func formatReportMetric(value *float64) string {
+	if value == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.6g", *value)
+}
+
+// escapeMarkdownCell escapes text for a Markdown table cell
+func escapeMarkdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "<", "&lt;", "\n", " ").Replace(text)
+}
+
+// writeModelCardMarkdown renders a model card as Markdown, linking the loss
+// curve SVG at lossCurveLink if not empty
+func writeModelCardMarkdown(w io.Writer, card *ModelCard, lossCurveLink string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", card.Title)
+	fmt.Fprintf(&b, "- Artifact: `%s`\n", card.Model)
+	fmt.Fprintf(&b, "- Task Type: %s\n", card.TaskType)
+	fmt.Fprintf(&b, "- Interval: %d\n", card.Interval)
+
+	fmt.Fprintf(&b, "\n## Hyperparameters\n\n| Name | Value |\n| --- | --- |\n")
+	for _, field := range append(card.Hyperparameters, card.Architecture...) {
+		fmt.Fprintf(&b, "| %s | %s |\n", field.Name, escapeMarkdownCell(field.Value))
+	}
+
+	fmt.Fprintf(&b, "\n## Features\n\n| Feature | Kind | Vocabulary | Transforms |\n| --- | --- | ---: | --- |\n")
+	for _, feature := range card.Features {
+		vocabulary := ""
+		if feature.Kind == "categorical" {
+			vocabulary = fmt.Sprintf("%d", feature.Vocabulary)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", escapeMarkdownCell(feature.Name), feature.Kind, vocabulary, escapeMarkdownCell(feature.Transforms))
+	}
+
+	fmt.Fprintf(&b, "\n## Training\n\n")
+	fmt.Fprintf(&b, "- Total Training Time: %s\n", formatDuration(card.TotalTrainingTime))
+	if summary := lossSummary(card.TrainLosses); summary != "" {
+		fmt.Fprintf(&b, "- Train Loss: %s\n", summary)
+	}
+	if lossCurveLink != "" {
+		fmt.Fprintf(&b, "\n![Training loss](%s)\n", lossCurveLink)
+	}
+
+	fmt.Fprintf(&b, "\n## Go vs Python Parity\n\n")
+	if parity := card.Parity; parity == nil {
+		fmt.Fprintf(&b, "Not run.\n")
+	} else {
+		fmt.Fprintf(&b, "- Verdict: **%s**\n", parity.Verdict)
+		fmt.Fprintf(&b, "- Comparison: %s (tolerance %g)\n", parity.Comparison.Mode, parity.Comparison.Tolerance)
+		fmt.Fprintf(&b, "- Samples: %d exact, %d close, %d different of %d\n", parity.Counts.Exact, parity.Counts.Close, parity.Counts.Diff, parity.Counts.Total)
+		for _, column := range parity.Metrics {
+			fmt.Fprintf(&b, "\n| %s | Value |\n| --- | ---: |\n", escapeMarkdownCell(column.Title))
+			for _, metric := range column.Metrics {
+				fmt.Fprintf(&b, "| %s | %s |\n", escapeMarkdownCell(metric.Label), formatReportMetric(metric.Value))
+			}
+		}
+	}
+
+	if len(card.Problems) > 0 {
+		fmt.Fprintf(&b, "\n## Consistency Problems\n\n")
+		for _, problem := range card.Problems {
+			fmt.Fprintf(&b, "- %s\n", problem)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// modelCardTemplate renders a model card as a standalone HTML page
+var modelCardTemplate = template.Must(template.New("card").Funcs(template.FuncMap{
+	"metric":   formatReportMetric,
+	"duration": formatDuration,
+	"losses":   lossSummary,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; text-align: left; }
+td.number { text-align: right; }
+.exact, .close { color: #2a7d2a; } .fail { color: #b22; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<ul>
+<li>Artifact: <code>{{.Model}}</code></li>
+<li>Task Type: {{.TaskType}}</li>
+<li>Interval: {{.Interval}}</li>
+</ul>
+
+<h2>Hyperparameters</h2>
+<table>
+<tr><th>Name</th><th>Value</th></tr>
+{{- range .Hyperparameters}}
+<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{- end}}
+{{- range .Architecture}}
+<tr><td>{{.Name}}</td><td>{{.Value}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Features</h2>
+<table>
+<tr><th>Feature</th><th>Kind</th><th>Vocabulary</th><th>Transforms</th></tr>
+{{- range .Features}}
+<tr><td>{{.Name}}</td><td>{{.Kind}}</td><td class="number">{{if eq .Kind "categorical"}}{{.Vocabulary}}{{end}}</td><td>{{.Transforms}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Training</h2>
+<ul>
+<li>Total Training Time: {{duration .TotalTrainingTime}}</li>
+{{- with losses .TrainLosses}}
+<li>Train Loss: {{.}}</li>
+{{- end}}
+</ul>
+{{.LossCurve}}
+
+<h2>Go vs Python Parity</h2>
+{{- with .Parity}}
+<ul>
+<li>Verdict: <strong class="{{.Verdict}}">{{.Verdict}}</strong></li>
+<li>Comparison: {{.Comparison.Mode}} (tolerance {{.Comparison.Tolerance}})</li>
+<li>Samples: {{.Counts.Exact}} exact, {{.Counts.Close}} close, {{.Counts.Diff}} different of {{.Counts.Total}}</li>
+</ul>
+{{- range .Metrics}}
+<table>
+<tr><th>{{.Title}}</th><th>Value</th></tr>
+{{- range .Metrics}}
+<tr><td>{{.Label}}</td><td class="number">{{metric .Value}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- else}}
+<p>Not run.</p>
+{{- end}}
+{{- with .Problems}}
+
+<h2>Consistency Problems</h2>
+<ul>
+{{- range .}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
+
+// writeModelCardHTML renders a model card as a self-contained HTML page
+// with the loss curve inlined as SVG
+func writeModelCardHTML(w io.Writer, card *ModelCard) error {
+	return modelCardTemplate.Execute(w, struct {
+		*ModelCard
+		LossCurve template.HTML
+	}{card, template.HTML(lossCurveSVG(card.TrainLosses))})
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Derivations supported by OpenRTBField
+const (
+	DeriveNone          = ""
+	DerivePlatform      = "platform"
+	DeriveMajorVersion  = "major_version"
+	DerivePlacementType = "placement_type"
+	DeriveUAPlatform    = "ua_platform"
+	DeriveUAMajorOS     = "ua_major_os_version"
+)
+
+// OpenRTBField describes where a feature is read from in a bid request.
+// Path is a dotted path into the request JSON, with numeric segments
+// indexing arrays (e.g. "imp.0.banner.w"). Derive optionally post-processes
+// the value found at Path.
+type OpenRTBField struct {
+	Path   string `json:"path"`
+	Derive string `json:"derive,omitempty"`
+	// UserAgentPath names a User-Agent field parsed when Path is absent or
+	// empty; only platform and major version derivations support it
+	UserAgentPath string `json:"ua_path,omitempty"`
+}
+
+// OpenRTBMapping maps feature names to their OpenRTB source fields
+type OpenRTBMapping map[string]OpenRTBField
+
+// OpenRTBExtractor builds ValidationData samples from OpenRTB 2.x bid requests
+type OpenRTBExtractor struct {
+	featureInfo FeatureInfo
+	mapping     OpenRTBMapping
+	// vocabularies holds the encoder classes of each derived feature;
+	// derived values outside them are treated as absent
+	vocabularies map[string]map[string]bool
+}
+
+// defaultOpenRTBMapping returns the mapping for the standard feature set.
+// rtb_id has no standard OpenRTB field and is read from the request ext.
+func defaultOpenRTBMapping() OpenRTBMapping {
+	return OpenRTBMapping{
+		"rtb_id":            {Path: "ext.rtb_id"},
+		"platform":          {Path: "device.os", Derive: DerivePlatform, UserAgentPath: "device.ua"},
+		"geo":               {Path: "device.geo.country"},
+		"do_not_track":      {Path: "device.dnt"},
+		"major_os_version":  {Path: "device.osv", Derive: DeriveMajorVersion, UserAgentPath: "device.ua"},
+		"placement_type":    {Path: "imp.0", Derive: DerivePlacementType},
+		"pub_app_object_id": {Path: "app.id"},
+	}
+}
+
+// loadOpenRTBMapping reads a JSON mapping file and overlays it on the default mapping
+func loadOpenRTBMapping(filePath string) (OpenRTBMapping, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %w", err)
+	}
+
+	var overrides OpenRTBMapping
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file: %w", err)
+	}
+
+	mapping := defaultOpenRTBMapping()
+	for feature, field := range overrides {
+		mapping[feature] = field
+	}
+	return mapping, nil
+}
+
+// newOpenRTBExtractor creates an extractor for the features in featureInfo.
+// Every numerical and categorical feature must have a mapping entry.
+func newOpenRTBExtractor(featureInfo FeatureInfo, mapping OpenRTBMapping) (*OpenRTBExtractor, error) {
+	encoders := featureInfo.MissingValueHandling.LabelEncoders
+	extractor := &OpenRTBExtractor{featureInfo: featureInfo, mapping: mapping, vocabularies: make(map[string]map[string]bool)}
+	for _, kind := range []string{"numerical", "categorical"} {
+		for _, featureName := range featureInfo.FeatureNames[kind] {
+			field, exists := mapping[featureName]
+			if !exists {
+				return nil, fmt.Errorf("no OpenRTB mapping for %s feature %s", kind, featureName)
+			}
+			switch field.Derive {
+			case DeriveNone, DerivePlacementType:
+				if field.UserAgentPath != "" {
+					return nil, fmt.Errorf("feature %s sets ua_path, which only platform and major version derivations support", featureName)
+				}
+			case DerivePlatform, DeriveMajorVersion, DeriveUAPlatform, DeriveUAMajorOS:
+			default:
+				return nil, fmt.Errorf("unknown derivation %q for feature %s", field.Derive, featureName)
+			}
+			if field.Derive != DeriveNone {
+				extractor.vocabularies[featureName] = classSet(encoders, featureName)
+			}
+		}
+	}
+	return extractor, nil
+}
+
+// Extract converts a raw OpenRTB bid request into the feature map expected
+// by prepareValidationInput. Absent fields are filled with the artifact's
+// missing values.
+func (e *OpenRTBExtractor) Extract(bidRequest []byte) (ValidationData, error) {
+	decoder := json.NewDecoder(bytes.NewReader(bidRequest))
+	decoder.UseNumber()
+
+	var request map[string]interface{}
+	if err := decoder.Decode(&request); err != nil {
+		return nil, fmt.Errorf("failed to parse bid request: %w", err)
+	}
+
+	missing := e.featureInfo.MissingValueHandling
+	sample := make(ValidationData)
+
+	for _, featureName := range e.featureInfo.FeatureNames["numerical"] {
+		value, found := lookupJSONPath(request, e.mapping[featureName].Path)
+		if !found || value == nil {
+			value = missing.NumericalMissingValue
+		}
+		sample[featureName] = value
+	}
+
+	for _, featureName := range e.featureInfo.FeatureNames["categorical"] {
+		field := e.mapping[featureName]
+		value, found := lookupJSONPath(request, field.Path)
+		category := ""
+		if found && value != nil {
+			category = e.inVocabulary(featureName, derive(value, field.Derive))
+		}
+		if category == "" && field.UserAgentPath != "" {
+			if userAgent, found := lookupJSONPath(request, field.UserAgentPath); found && userAgent != nil {
+				category = e.inVocabulary(featureName, deriveFromUserAgent(fmt.Sprintf("%v", userAgent), field.Derive))
+			}
+		}
+		if category == "" {
+			category = missing.CategoricalMissingValue
+		}
+		sample[featureName] = category
+	}
+
+	return sample, nil
+}
+
+// lookupJSONPath walks a dotted path through decoded JSON objects and arrays
+func lookupJSONPath(root interface{}, path string) (interface{}, bool) {
+	current := root
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, exists := node[segment]
+			if !exists {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// inVocabulary returns a derived value if its feature's encoder knows it,
+// otherwise an empty string. Features without an encoder keep any value.
+func (e *OpenRTBExtractor) inVocabulary(featureName string, value string) string {
+	classes := e.vocabularies[featureName]
+	if classes != nil && !classes[value] {
+		return ""
+	}
+	return value
+}
+
+// derive turns a raw OpenRTB value into a categorical string
+func derive(value interface{}, derivation string) string {
+	switch derivation {
+	case DerivePlatform:
+		return platformFromOS(fmt.Sprintf("%v", value))
+	case DeriveMajorVersion:
+		return majorVersion(fmt.Sprintf("%v", value))
+	case DeriveUAPlatform, DeriveUAMajorOS:
+		return deriveFromUserAgent(fmt.Sprintf("%v", value), derivation)
+	case DerivePlacementType:
+		imp, ok := value.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		return placementTypeFromImp(imp)
+	default:
+		return fmt.Sprintf("%v", value)
+	}
+}
+
+// deriveFromUserAgent parses the platform or the major OS version from a
+// User-Agent, depending on the derivation
+func deriveFromUserAgent(userAgent string, derivation string) string {
+	platform, osVersion := parseUserAgentOS(userAgent)
+	if derivation == DerivePlatform || derivation == DeriveUAPlatform {
+		return platform
+	}
+	return osVersion
+}
+
+// platformFromOS maps an OpenRTB device.os value to the platform vocabulary
+func platformFromOS(os string) string {
+	switch strings.ToLower(strings.TrimSpace(os)) {
+	case "android":
+		return "android"
+	case "ios", "iphone os", "ipados":
+		return "iOS"
+	default:
+		return os
+	}
+}
+
+// majorVersion returns the major component of a dotted version string
+func majorVersion(version string) string {
+	version = strings.TrimSpace(version)
+	if dot := strings.IndexByte(version, '.'); dot >= 0 {
+		return version[:dot]
+	}
+	return version
+}
+
+// placementTypeFromImp classifies an impression object into the placement
+// type vocabulary: rewarded, interstitial, native, in_line, mrec or banner
+func placementTypeFromImp(imp map[string]interface{}) string {
+	if jsonFlag(imp["rwdd"]) {
+		return "rewarded"
+	}
+	if jsonFlag(imp["instl"]) {
+		return "interstitial"
+	}
+	if _, ok := imp["native"]; ok {
+		return "native"
+	}
+	if _, ok := imp["video"]; ok {
+		return "in_line"
+	}
+	if banner, ok := imp["banner"].(map[string]interface{}); ok {
+		if fmt.Sprintf("%v", banner["w"]) == "300" && fmt.Sprintf("%v", banner["h"]) == "250" {
+			return "mrec"
+		}
+		return "banner"
+	}
+	return ""
+}
+
+// jsonFlag reports whether an OpenRTB integer flag is set to 1
+func jsonFlag(value interface{}) bool {
+	return fmt.Sprintf("%v", value) == "1"
+}
+
+// extractBidRequests reads a JSONL file of bid requests, one per line, and
+// extracts a sample from each
+func extractBidRequests(r io.Reader, extractor *OpenRTBExtractor) ([]ValidationData, error) {
+	var samples []ValidationData
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		sample, err := extractor.Extract(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bid requests: %w", err)
+	}
+	return samples, nil
+}
+
+// runExtract converts a JSONL file of OpenRTB bid requests into a JSONL
+// dataset of the artifact's features
+func runExtract(args []string) {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	artifact := addArtifactFlags(flags)
+	requestsPath := flags.String("requests", "", "JSONL file of OpenRTB bid requests, one per line")
+	mappingPath := flags.String("mapping", "", "JSON file of feature mappings overlaid on the default OpenRTB mapping")
+	outputPath := flags.String("out", "", "write the extracted samples to this path instead of stdout")
+	flags.Parse(args)
+
+	if *requestsPath == "" {
+		log.Fatalf("Invalid flags: -requests is required")
+	}
+
+	_, torchData, err := artifact.load()
+	if err != nil {
+		log.Fatalf("Failed to load model data: %v", err)
+	}
+
+	mapping := defaultOpenRTBMapping()
+	if *mappingPath != "" {
+		if mapping, err = loadOpenRTBMapping(*mappingPath); err != nil {
+			log.Fatalf("Failed to load OpenRTB mapping: %v", err)
+		}
+	}
+	extractor, err := newOpenRTBExtractor(torchData.FeatureInfo, mapping)
+	if err != nil {
+		log.Fatalf("Failed to create OpenRTB extractor: %v", err)
+	}
+
+	file, err := os.Open(*requestsPath)
+	if err != nil {
+		log.Fatalf("Failed to open bid requests: %v", err)
+	}
+	samples, err := extractBidRequests(file, extractor)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to extract features: %v", err)
+	}
+
+	output := os.Stdout
+	if *outputPath != "" {
+		if output, err = os.Create(*outputPath); err != nil {
+			log.Fatalf("Failed to create output file: %v", err)
+		}
+	}
+	encoder := json.NewEncoder(output)
+	for _, sample := range samples {
+		if err := encoder.Encode(sample); err != nil {
+			log.Fatalf("Failed to write samples: %v", err)
+		}
+	}
+	if output != os.Stdout {
+		if err := output.Close(); err != nil {
+			log.Fatalf("Failed to write samples: %v", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Extracted %d samples from %s\n", len(samples), *requestsPath)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// artifactFeatureInfo returns the feature info of the bundled artifact
+func artifactFeatureInfo(t *testing.T) FeatureInfo {
+	t.Helper()
+	artifact, err := readArtifactFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, torchData, err := parseModelData(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return torchData.FeatureInfo
+}
+
+func TestOpenRTBExtract(t *testing.T) {
+	extractor, err := newOpenRTBExtractor(artifactFeatureInfo(t), defaultOpenRTBMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const androidUA = "Mozilla/5.0 (Linux; Android 13; Pixel 7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Mobile Safari/537.36"
+	tests := []struct {
+		name    string
+		request string
+		want    map[string]string
+	}{
+		{
+			name:    "structured device fields",
+			request: `{"device": {"os": "Android", "osv": "14.0.1", "dnt": 1, "geo": {"country": "DE"}}, "app": {"id": "app-1"}, "ext": {"rtb_id": "r1"}}`,
+			want:    map[string]string{"platform": "android", "major_os_version": "14", "do_not_track": "1", "geo": "DE", "pub_app_object_id": "app-1", "rtb_id": "r1"},
+		},
+		{
+			name:    "iOS names",
+			request: `{"device": {"os": "iPadOS", "osv": "17.4"}}`,
+			want:    map[string]string{"platform": "iOS", "major_os_version": "17"},
+		},
+		{
+			name:    "platform outside the vocabulary",
+			request: `{"device": {"os": "Windows", "osv": "10"}}`,
+			want:    map[string]string{"platform": "unknown", "major_os_version": "10"},
+		},
+		{
+			name:    "major version outside the vocabulary",
+			request: `{"device": {"os": "Android", "osv": "4.4"}}`,
+			want:    map[string]string{"platform": "android", "major_os_version": "unknown"},
+		},
+		{
+			name:    "out-of-vocabulary values fall back to the User-Agent",
+			request: `{"device": {"os": "Windows", "osv": "4.4", "ua": "` + androidUA + `"}}`,
+			want:    map[string]string{"platform": "android", "major_os_version": "13"},
+		},
+		{
+			name:    "absent fields fall back to the User-Agent",
+			request: `{"device": {"ua": "` + androidUA + `"}}`,
+			want:    map[string]string{"platform": "android", "major_os_version": "13"},
+		},
+		{
+			name:    "empty request",
+			request: `{}`,
+			want:    map[string]string{"platform": "unknown", "major_os_version": "unknown", "placement_type": "unknown", "geo": "unknown", "rtb_id": "unknown"},
+		},
+		{
+			name:    "rewarded placement",
+			request: `{"imp": [{"rwdd": 1, "video": {}}]}`,
+			want:    map[string]string{"placement_type": "rewarded"},
+		},
+		{
+			name:    "mrec placement",
+			request: `{"imp": [{"banner": {"w": 300, "h": 250}}]}`,
+			want:    map[string]string{"placement_type": "mrec"},
+		},
+		{
+			name:    "banner placement",
+			request: `{"imp": [{"banner": {"w": 320, "h": 50}}]}`,
+			want:    map[string]string{"placement_type": "banner"},
+		},
+	}
+	for _, tc := range tests {
+		sample, err := extractor.Extract([]byte(tc.request))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		for featureName, want := range tc.want {
+			if got := sample[featureName]; got != want {
+				t.Errorf("%s: %s = %v, want %q", tc.name, featureName, got, want)
+			}
+		}
+	}
+
+	if _, err := extractor.Extract([]byte(`{"device": `)); err == nil {
+		t.Errorf("truncated bid request was accepted")
+	}
+}
+
+func TestNewOpenRTBExtractorErrors(t *testing.T) {
+	featureInfo := artifactFeatureInfo(t)
+	tests := []struct {
+		name     string
+		override OpenRTBMapping
+		want     string
+	}{
+		{"unknown derivation", OpenRTBMapping{"geo": {Path: "device.geo.country", Derive: "region"}}, "unknown derivation"},
+		{"ua_path without a platform derivation", OpenRTBMapping{"geo": {Path: "device.geo.country", UserAgentPath: "device.ua"}}, "ua_path"},
+		{"ua_path on placement type", OpenRTBMapping{"placement_type": {Path: "imp.0", Derive: DerivePlacementType, UserAgentPath: "device.ua"}}, "ua_path"},
+	}
+	for _, tc := range tests {
+		mapping := defaultOpenRTBMapping()
+		for featureName, field := range tc.override {
+			mapping[featureName] = field
+		}
+		_, err := newOpenRTBExtractor(featureInfo, mapping)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+
+	mapping := defaultOpenRTBMapping()
+	delete(mapping, "geo")
+	if _, err := newOpenRTBExtractor(featureInfo, mapping); err == nil || !strings.Contains(err.Error(), "geo") {
+		t.Errorf("missing mapping: error = %v, want one naming geo", err)
+	}
+}
+
+func TestLoadOpenRTBMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mapping.json")
+	if err := os.WriteFile(path, []byte(`{"rtb_id": {"path": "source.ext.rtb_id"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mapping, err := loadOpenRTBMapping(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mapping["rtb_id"].Path; got != "source.ext.rtb_id" {
+		t.Errorf("rtb_id path = %q, want the override", got)
+	}
+	if got := mapping["platform"]; got != defaultOpenRTBMapping()["platform"] {
+		t.Errorf("platform mapping = %+v, want the default", got)
+	}
+
+	if _, err := loadOpenRTBMapping(filepath.Join(t.TempDir(), "absent.json")); err == nil {
+		t.Errorf("missing mapping file was accepted")
+	}
+}
+
+func TestExtractBidRequests(t *testing.T) {
+	extractor, err := newOpenRTBExtractor(artifactFeatureInfo(t), defaultOpenRTBMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples, err := extractBidRequests(strings.NewReader("{\"device\": {\"os\": \"iOS\"}}\n\n{}\n"), extractor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0]["platform"] != "iOS" {
+		t.Errorf("samples = %v, want two with the first on iOS", samples)
+	}
+
+	_, err = extractBidRequests(strings.NewReader("{}\n{\"device\": \n"), extractor)
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("malformed request: error = %v, want one naming line 2", err)
+	}
+}
@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// Inner artifact fields that unpack moves into their own files, by file.
+// Every other field, and a field whose file is not written, is kept
+// verbatim in metadata.json.
+var unpackedFields = map[string]string{
+	"feature_info":           bundleFeatureInfoFile,
+	"validation_data":        bundleValidationFile,
+	"validation_predictions": bundleValidationFile,
+	"training_history":       bundleTrainingHistoryFile,
+}
+
+// runUnpack extracts a model.json artifact into a model directory
+func runUnpack(args []string) {
+	flags := flag.NewFlagSet("unpack", flag.ExitOnError)
+	modelPath := flags.String("model", "data/model.json", "path to the model JSON file")
+	outDir := flags.String("out", "", "directory to write the unpacked files to")
+	verify := flags.Bool("verify", true, "repack the directory and check that it matches the artifact")
+	flags.Parse(args)
+
+	if *outDir == "" {
+		log.Fatalf("Invalid flags: -out is required")
+	}
+	artifact, err := readArtifactFile(*modelPath)
+	if err != nil {
+		log.Fatalf("Failed to read artifact: %v", err)
+	}
+
+	files, err := unpackArtifact(artifact)
+	if err != nil {
+		log.Fatalf("Failed to unpack %s: %v", *modelPath, err)
+	}
+	if err := writeBundleFiles(*outDir, files); err != nil {
+		log.Fatalf("Failed to write %s: %v", *outDir, err)
+	}
+	fmt.Printf("Unpacked %s into %s\n", *modelPath, *outDir)
+	for _, name := range sortedFileNames(files) {
+		fmt.Printf("- %s (%d bytes)\n", name, len(files[name]))
+	}
+
+	if *verify {
+		repacked, err := packBundle(*outDir, "")
+		if err != nil {
+			log.Fatalf("Round-trip check failed: %v", err)
+		}
+		if err := compareArtifacts(artifact, repacked); err != nil {
+			log.Fatalf("Round-trip check failed: %v", err)
+		}
+		fmt.Printf("✅ Round trip verified: repacking %s reproduces %s\n", *outDir, *modelPath)
+	}
+}
+
+// runPack builds a model.json artifact from a model directory
+func runPack(args []string) {
+	flags := flag.NewFlagSet("pack", flag.ExitOnError)
+	dir := flags.String("dir", "", "model directory to pack")
+	outPath := flags.String("out", "", "path of the model JSON file to write")
+	verify := flags.Bool("verify", true, "load the packed artifact and check that it matches the directory")
+	force := flags.Bool("force", false, "pack the directory even if it fails the consistency checks")
+	compression := flags.String("compress-model", "", "compress the model payload: gzip or none (default: as in metadata.json)")
+	flags.Parse(args)
+
+	if *dir == "" || *outPath == "" {
+		log.Fatalf("Invalid flags: -dir and -out are required")
+	}
+	_, bundleData, err := loadArtifact(*dir, LoadOptions{Force: *force})
+	if err != nil {
+		log.Fatalf("Failed to load %s: %v", *dir, err)
+	}
+
+	artifact, err := packBundle(*dir, *compression)
+	if err != nil {
+		log.Fatalf("Failed to pack %s: %v", *dir, err)
+	}
+	if err := writeArtifactFile(*outPath, artifact); err != nil {
+		log.Fatalf("Failed to write artifact: %v", err)
+	}
+	fmt.Printf("Packed %s into %s (%d bytes)\n", *dir, *outPath, len(artifact))
+
+	if *verify {
+		_, packedData, err := parseModelData(artifact)
+		if err != nil {
+			log.Fatalf("Round-trip check failed: %v", err)
+		}
+		if packedData.ModelBytes, err = decodeModelBytes(packedData); err != nil {
+			log.Fatalf("Round-trip check failed: %v", err)
+		}
+		packedData.TorchModel.Model = ""
+		// -compress-model may change how the payload is stored, not what it holds
+		packedData.TorchModel.Compression = bundleData.TorchModel.Compression
+		if !reflect.DeepEqual(bundleData, packedData) {
+			log.Fatalf("Round-trip check failed: %s loads differently from %s", *outPath, *dir)
+		}
+		fmt.Printf("✅ Round trip verified: %s loads identically to %s\n", *outPath, *dir)
+	}
+}
+
+// unpackArtifact splits a model.json artifact into model directory files.
+// JSON values are copied verbatim, so unknown fields and number formatting
+// survive a round trip through packBundle.
+func unpackArtifact(artifact []byte) (map[string][]byte, error) {
+	outer, inner, err := parseRawArtifact(artifact)
+	if err != nil {
+		return nil, err
+	}
+	if _, encrypted := inner["encryption"]; encrypted {
+		return nil, fmt.Errorf("artifact is encrypted; unpacking would write it to disk in plaintext")
+	}
+	if _, err := rawSchemaVersion(inner); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte)
+
+	// The model payload becomes model.pt; the rest of torch_model stays in metadata
+	var torchModel map[string]json.RawMessage
+	if err := json.Unmarshal(inner["torch_model"], &torchModel); err != nil {
+		return nil, fmt.Errorf("failed to parse torch_model: %w", err)
+	}
+	var payload string
+	if err := json.Unmarshal(torchModel["model"], &payload); err != nil {
+		return nil, fmt.Errorf("failed to read torch_model.model: %w", err)
+	}
+	var compression string
+	if raw, exists := torchModel["compression"]; exists {
+		if err := json.Unmarshal(raw, &compression); err != nil {
+			return nil, fmt.Errorf("failed to read torch_model.compression: %w", err)
+		}
+	}
+	if files[bundleModelFile], err = decodeModelPayload(payload, compression); err != nil {
+		return nil, err
+	}
+	delete(torchModel, "model")
+
+	if raw, exists := inner["feature_info"]; exists {
+		if files[bundleFeatureInfoFile], err = indentJSON(raw); err != nil {
+			return nil, fmt.Errorf("feature_info: %w", err)
+		}
+	}
+	if raw, exists := inner["training_history"]; exists {
+		if files[bundleTrainingHistoryFile], err = indentJSON(raw); err != nil {
+			return nil, fmt.Errorf("training_history: %w", err)
+		}
+	}
+	validation, err := unpackValidation(inner["validation_data"], inner["validation_predictions"])
+	if err != nil {
+		return nil, err
+	}
+	if validation != nil {
+		files[bundleValidationFile] = validation
+	}
+
+	metadata := make(map[string]json.RawMessage)
+	for key, raw := range inner {
+		metadata[key] = raw
+	}
+	for key, file := range unpackedFields {
+		if _, written := files[file]; written {
+			delete(metadata, key)
+		}
+	}
+	if metadata["torch_model"], err = marshalJSON(torchModel, ""); err != nil {
+		return nil, err
+	}
+	if interval, exists := outer["interval"]; exists {
+		metadata["interval"] = interval
+	}
+	if files[bundleMetadataFile], err = marshalJSON(metadata, "  "); err != nil {
+		return nil, err
+	}
+	files[bundleMetadataFile] = append(files[bundleMetadataFile], '\n')
+
+	return files, nil
+}
+
+// unpackValidation zips validation samples with their predictions, one
+// JSONL line per sample; multiclass samples get a list of scores. It
+// returns nil when there are no samples or no predictions to zip, so both
+// fields stay in metadata.
+func unpackValidation(rawData json.RawMessage, rawPredictions json.RawMessage) ([]byte, error) {
+	var samples []json.RawMessage
+	if len(rawData) > 0 {
+		if err := json.Unmarshal(rawData, &samples); err != nil {
+			return nil, fmt.Errorf("failed to parse validation_data: %w", err)
+		}
+	}
+	var predictions []json.RawMessage
+	if len(rawPredictions) > 0 {
+		if err := json.Unmarshal(rawPredictions, &predictions); err != nil {
+			return nil, fmt.Errorf("failed to parse validation_predictions: %w", err)
+		}
+	}
+	if len(samples) == 0 || len(predictions) == 0 {
+		return nil, nil
+	}
+	if len(predictions)%len(samples) != 0 {
+		return nil, fmt.Errorf("cannot split %d validation predictions into %d samples", len(predictions), len(samples))
+	}
+	scoresPerSample := len(predictions) / len(samples)
+
+	var out bytes.Buffer
+	for i, sample := range samples {
+		prediction := predictions[i]
+		if scoresPerSample > 1 {
+			scores, err := marshalJSON(predictions[i*scoresPerSample:(i+1)*scoresPerSample], "")
+			if err != nil {
+				return nil, err
+			}
+			prediction = scores
+		}
+		line, err := marshalJSON(bundleValidationLine{Features: sample, Prediction: prediction}, "")
+		if err != nil {
+			return nil, err
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// packBundle builds a model.json artifact from a model directory, the
+// inverse of unpackArtifact. compression overrides the model compression
+// from metadata.json: "gzip", "none", or empty to keep it.
+func packBundle(dir string, compression string) ([]byte, error) {
+	inner := make(map[string]json.RawMessage)
+	outer := make(map[string]json.RawMessage)
+
+	if metadata, err := os.ReadFile(filepath.Join(dir, bundleMetadataFile)); err == nil {
+		if err := json.Unmarshal(metadata, &inner); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", bundleMetadataFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if interval, exists := inner["interval"]; exists {
+		outer["interval"] = interval
+		delete(inner, "interval")
+	} else {
+		outer["interval"] = json.RawMessage("0")
+	}
+
+	torchModel := make(map[string]json.RawMessage)
+	if raw, exists := inner["torch_model"]; exists {
+		if err := json.Unmarshal(raw, &torchModel); err != nil {
+			return nil, fmt.Errorf("failed to parse torch_model in %s: %w", bundleMetadataFile, err)
+		}
+	}
+	modelBytes, err := os.ReadFile(filepath.Join(dir, bundleModelFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read model: %w", err)
+	}
+	switch compression {
+	case "":
+		if raw, exists := torchModel["compression"]; exists {
+			if err := json.Unmarshal(raw, &compression); err != nil {
+				return nil, fmt.Errorf("failed to read torch_model.compression: %w", err)
+			}
+		}
+	case "none":
+		compression = ""
+		delete(torchModel, "compression")
+	default:
+		if torchModel["compression"], err = marshalJSON(compression, ""); err != nil {
+			return nil, err
+		}
+	}
+	if modelBytes, err = compressModel(modelBytes, compression); err != nil {
+		return nil, err
+	}
+	if torchModel["model"], err = marshalJSON(base64.StdEncoding.EncodeToString(modelBytes), ""); err != nil {
+		return nil, err
+	}
+	if inner["torch_model"], err = marshalJSON(torchModel, ""); err != nil {
+		return nil, err
+	}
+
+	for field, name := range map[string]string{"feature_info": bundleFeatureInfoFile, "training_history": bundleTrainingHistoryFile} {
+		raw, err := os.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid(raw) {
+			return nil, fmt.Errorf("%s is not valid JSON", name)
+		}
+		inner[field] = raw
+	}
+
+	validation, err := os.ReadFile(filepath.Join(dir, bundleValidationFile))
+	switch {
+	case err == nil:
+		if inner["validation_data"], inner["validation_predictions"], err = packValidation(validation); err != nil {
+			return nil, fmt.Errorf("%s: %w", bundleValidationFile, err)
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+
+	return encodeRawArtifact(outer, inner)
+}
+
+// packValidation splits validation.jsonl back into the validation_data and
+// validation_predictions arrays
+func packValidation(validation []byte) (json.RawMessage, json.RawMessage, error) {
+	samples := []json.RawMessage{}
+	predictions := []json.RawMessage{}
+	for i, line := range bytes.Split(validation, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var sample bundleValidationLine
+		if err := json.Unmarshal(line, &sample); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if len(sample.Features) == 0 || len(sample.Prediction) == 0 {
+			return nil, nil, fmt.Errorf("line %d: features and prediction are required", i+1)
+		}
+		samples = append(samples, sample.Features)
+		if sample.Prediction[0] == '[' {
+			var scores []json.RawMessage
+			if err := json.Unmarshal(sample.Prediction, &scores); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			predictions = append(predictions, scores...)
+		} else {
+			predictions = append(predictions, sample.Prediction)
+		}
+	}
+
+	rawSamples, err := marshalJSON(samples, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	rawPredictions, err := marshalJSON(predictions, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return rawSamples, rawPredictions, nil
+}
+
+// compareArtifacts checks that two model.json artifacts hold the same
+// values, comparing the nested data JSON by value and numbers by their text
+func compareArtifacts(a []byte, b []byte) error {
+	decode := func(artifact []byte) (map[string]interface{}, error) {
+		var outer map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(artifact))
+		decoder.UseNumber()
+		if err := decoder.Decode(&outer); err != nil {
+			return nil, err
+		}
+		data, ok := outer["data"].(string)
+		if !ok {
+			return nil, fmt.Errorf("data field is not a string")
+		}
+		var inner interface{}
+		decoder = json.NewDecoder(bytes.NewReader([]byte(data)))
+		decoder.UseNumber()
+		if err := decoder.Decode(&inner); err != nil {
+			return nil, fmt.Errorf("failed to parse inner JSON: %w", err)
+		}
+		// Compare model payloads by content, since recompressing need not
+		// reproduce the same bytes
+		if torchModel, ok := inner.(map[string]interface{})["torch_model"].(map[string]interface{}); ok {
+			if payload, ok := torchModel["model"].(string); ok {
+				compression, _ := torchModel["compression"].(string)
+				model, err := decodeModelPayload(payload, compression)
+				if err != nil {
+					return nil, err
+				}
+				torchModel["model"] = model
+			}
+		}
+		outer["data"] = inner
+		return outer, nil
+	}
+
+	left, err := decode(a)
+	if err != nil {
+		return fmt.Errorf("original artifact: %w", err)
+	}
+	right, err := decode(b)
+	if err != nil {
+		return fmt.Errorf("repacked artifact: %w", err)
+	}
+	for _, key := range []string{"interval", "data"} {
+		if !reflect.DeepEqual(left[key], right[key]) {
+			return fmt.Errorf("field %s differs after the round trip", key)
+		}
+	}
+	if !reflect.DeepEqual(left, right) {
+		return fmt.Errorf("top-level fields differ after the round trip")
+	}
+	return nil
+}
+
+// marshalJSON encodes v without escaping HTML characters, which numpy dtype
+// strings such as "<U7" contain, indenting by indent if not empty
+func marshalJSON(v interface{}, indent string) ([]byte, error) {
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	if indent != "" {
+		encoder.SetIndent("", indent)
+	}
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(out.Bytes(), []byte("\n")), nil
+}
+
+// parseRawArtifact parses a model.json artifact into its outer and inner
+// members, keeping every value verbatim
+func parseRawArtifact(artifact []byte) (map[string]json.RawMessage, map[string]json.RawMessage, error) {
+	var outer map[string]json.RawMessage
+	if err := json.Unmarshal(artifact, &outer); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse outer JSON: %w", err)
+	}
+	var data string
+	if err := json.Unmarshal(outer["data"], &data); err != nil {
+		return nil, nil, fmt.Errorf("failed to read data field: %w", err)
+	}
+	var inner map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &inner); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse inner JSON: %w", err)
+	}
+	return outer, inner, nil
+}
+
+// encodeRawArtifact nests the inner members into the outer data string
+func encodeRawArtifact(outer map[string]json.RawMessage, inner map[string]json.RawMessage) ([]byte, error) {
+	data, err := marshalJSON(inner, "")
+	if err != nil {
+		return nil, err
+	}
+	if outer["data"], err = marshalJSON(string(data), ""); err != nil {
+		return nil, err
+	}
+	return marshalJSON(outer, "")
+}
+
+// indentJSON pretty-prints a JSON value for a standalone file
+func indentJSON(raw json.RawMessage) ([]byte, error) {
+	var out bytes.Buffer
+	if err := json.Indent(&out, raw, "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+// writeBundleFiles writes model directory files, creating the directory
+func writeBundleFiles(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedFileNames returns the file names in ascending order
+func sortedFileNames(files map[string][]byte) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+// roundTrip unpacks artifact into a temporary directory and packs it back
+// with compression
+func roundTrip(t *testing.T, artifact []byte, compression string) []byte {
+	t.Helper()
+	files, err := unpackArtifact(artifact)
+	if err != nil {
+		t.Fatalf("unpack: %v", err)
+	}
+	dir := t.TempDir()
+	if err := writeBundleFiles(dir, files); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+	repacked, err := packBundle(dir, compression)
+	if err != nil {
+		t.Fatalf("pack: %v", err)
+	}
+	return repacked
+}
+
+// packedModel returns the decoded model payload of an artifact
+func packedModel(t *testing.T, artifact []byte) []byte {
+	t.Helper()
+	_, torchData, err := parseModelData(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelBytes, err := decodeModelBytes(torchData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return modelBytes
+}
+
+func TestPackRoundTrip(t *testing.T) {
+	artifact, err := readArtifactFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compareArtifacts(artifact, roundTrip(t, artifact, "")); err != nil {
+		t.Errorf("round trip: %v", err)
+	}
+}
+
+func TestPackRoundTripGzip(t *testing.T) {
+	artifact, err := readArtifactFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compressed := roundTrip(t, artifact, ModelCompressionGzip)
+	_, torchData, err := parseModelData(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if torchData.TorchModel.Compression != ModelCompressionGzip {
+		t.Fatalf("compression = %q, want %q", torchData.TorchModel.Compression, ModelCompressionGzip)
+	}
+	if string(packedModel(t, compressed)) != string(packedModel(t, artifact)) {
+		t.Errorf("gzip payload does not decompress to the original model")
+	}
+
+	// metadata.json keeps the compression, and -compress-model none drops it
+	if err := compareArtifacts(compressed, roundTrip(t, compressed, "")); err != nil {
+		t.Errorf("gzip round trip: %v", err)
+	}
+	if err := compareArtifacts(artifact, roundTrip(t, compressed, "none")); err != nil {
+		t.Errorf("decompressing round trip: %v", err)
+	}
+}
+
+func TestPackRoundTripSigned(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	artifact, err := readArtifactFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, torchData, err := parseModelData(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	modelBytes, err := decodeModelBytes(torchData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	integrity, err := computeIntegrity(torchData, modelBytes, "release", privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedPath := filepath.Join(t.TempDir(), "signed.json")
+	if err := writeArtifactIntegrity("data/model.json", signedPath, integrity); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := readArtifactFile(signedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustedKeys := map[string]ed25519.PublicKey{"release": publicKey}
+
+	for _, compression := range []string{"", ModelCompressionGzip} {
+		repacked := roundTrip(t, signed, compression)
+		if compression == "" {
+			if err := compareArtifacts(signed, repacked); err != nil {
+				t.Errorf("signed round trip: %v", err)
+			}
+		}
+		_, repackedData, err := parseModelData(repacked)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyIntegrity(repackedData, packedModel(t, repacked), trustedKeys); err != nil {
+			t.Errorf("compression %q: repacked signature: %v", compression, err)
+		}
+	}
+}
+
+func TestPackRoundTripUnzippableValidation(t *testing.T) {
+	artifact, err := readArtifactFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer, inner, err := parseRawArtifact(artifact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		edit   func(inner map[string]json.RawMessage)
+		fields []string
+	}{
+		{
+			name:   "predictions without samples",
+			edit:   func(inner map[string]json.RawMessage) { delete(inner, "validation_data") },
+			fields: []string{"validation_predictions"},
+		},
+		{
+			name:   "samples without predictions",
+			edit:   func(inner map[string]json.RawMessage) { inner["validation_predictions"] = json.RawMessage("[]") },
+			fields: []string{"validation_data", "validation_predictions"},
+		},
+		{
+			name:   "samples with predictions absent",
+			edit:   func(inner map[string]json.RawMessage) { delete(inner, "validation_predictions") },
+			fields: []string{"validation_data"},
+		},
+	}
+	for _, tc := range tests {
+		edited := make(map[string]json.RawMessage)
+		for key, raw := range inner {
+			edited[key] = raw
+		}
+		tc.edit(edited)
+		variant, err := encodeRawArtifact(outer, edited)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files, err := unpackArtifact(variant)
+		if err != nil {
+			t.Errorf("%s: unpack: %v", tc.name, err)
+			continue
+		}
+		if _, exists := files[bundleValidationFile]; exists {
+			t.Errorf("%s: %s written", tc.name, bundleValidationFile)
+		}
+		var metadata map[string]json.RawMessage
+		if err := json.Unmarshal(files[bundleMetadataFile], &metadata); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range tc.fields {
+			if _, exists := metadata[field]; !exists {
+				t.Errorf("%s: %s missing from %s", tc.name, field, bundleMetadataFile)
+			}
+		}
+		if err := compareArtifacts(variant, roundTrip(t, variant, "")); err != nil {
+			t.Errorf("%s: round trip: %v", tc.name, err)
+		}
+	}
+}
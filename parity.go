@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ComparisonMode selects how the distance between a Go prediction and the
+// Python prediction is measured
+type ComparisonMode string
+
+const (
+	// AbsoluteComparison compares |predicted - expected| with the tolerance
+	AbsoluteComparison ComparisonMode = "absolute"
+	// RelativeComparison compares |predicted - expected| / max(|predicted|, |expected|)
+	RelativeComparison ComparisonMode = "relative"
+	// ULPComparison compares the number of float32 values between the two predictions
+	ULPComparison ComparisonMode = "ulp"
+)
+
+// Match statuses reported per sample
+const (
+	MatchExact = "EXACT"
+	MatchClose = "CLOSE"
+	MatchDiff  = "DIFF"
+)
+
+// defaultTolerance is used when the artifact does not carry a validation tolerance
+const defaultTolerance = 1e-6
+
+// defaultULPTolerance is used in ULP mode unless a tolerance is given explicitly,
+// since the artifact tolerance is not a ULP count
+const defaultULPTolerance = 4
+
+// parseComparisonMode parses a comparison mode name
+func parseComparisonMode(name string) (ComparisonMode, error) {
+	switch mode := ComparisonMode(strings.ToLower(name)); mode {
+	case AbsoluteComparison, RelativeComparison, ULPComparison:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown comparison mode %q (expected absolute, relative or ulp)", name)
+	}
+}
+
+// ParityChecker compares Go predictions with reference predictions
+type ParityChecker struct {
+	Mode      ComparisonMode
+	Tolerance float64
+}
+
+// SampleParity is the comparison result for a single sample
+type SampleParity struct {
+	Index     int
+	Predicted float64
+	Expected  float64
+	Error     float64
+	Distance  float64
+	Status    string
+}
+
+// ParityResult summarizes the comparison of a set of predictions
+type ParityResult struct {
+	Mode      ComparisonMode
+	Tolerance float64
+	Samples   []SampleParity
+	Exact     int
+	Close     int
+	Diff      int
+}
+
+// newParityChecker creates a checker. A negative tolerance selects the
+// default: the artifact's validation tolerance, or a fixed ULP count in ULP mode.
+func newParityChecker(mode ComparisonMode, tolerance float64, artifactTolerance float64) *ParityChecker {
+	if tolerance < 0 {
+		switch {
+		case mode == ULPComparison:
+			tolerance = defaultULPTolerance
+		case artifactTolerance > 0:
+			tolerance = artifactTolerance
+		default:
+			tolerance = defaultTolerance
+		}
+	}
+	return &ParityChecker{Mode: mode, Tolerance: tolerance}
+}
+
+// Compare classifies each prediction as EXACT, CLOSE or DIFF
+func (c *ParityChecker) Compare(predicted []float64, expected []float64) (*ParityResult, error) {
+	if len(predicted) != len(expected) {
+		return nil, fmt.Errorf("got %d predictions but %d expected values", len(predicted), len(expected))
+	}
+
+	result := &ParityResult{
+		Mode:      c.Mode,
+		Tolerance: c.Tolerance,
+		Samples:   make([]SampleParity, len(predicted)),
+	}
+
+	for i := range predicted {
+		sample := SampleParity{
+			Index:     i,
+			Predicted: predicted[i],
+			Expected:  expected[i],
+			Error:     predicted[i] - expected[i],
+			Distance:  c.distance(predicted[i], expected[i]),
+		}
+
+		switch {
+		case predicted[i] == expected[i]:
+			sample.Status = MatchExact
+			result.Exact++
+		case sample.Distance <= c.Tolerance:
+			sample.Status = MatchClose
+			result.Close++
+		default:
+			sample.Status = MatchDiff
+			result.Diff++
+		}
+		result.Samples[i] = sample
+	}
+
+	return result, nil
+}
+
+// distance measures how far apart two predictions are in the checker's mode
+func (c *ParityChecker) distance(predicted float64, expected float64) float64 {
+	switch c.Mode {
+	case RelativeComparison:
+		scale := math.Max(math.Abs(predicted), math.Abs(expected))
+		if scale == 0 {
+			return 0
+		}
+		return math.Abs(predicted-expected) / scale
+	case ULPComparison:
+		return float64(ulpDistance(float32(predicted), float32(expected)))
+	default:
+		return math.Abs(predicted - expected)
+	}
+}
+
+// ulpDistance returns the number of representable float32 values between a and b
+func ulpDistance(a float32, b float32) uint64 {
+	if math.IsNaN(float64(a)) || math.IsNaN(float64(b)) {
+		return math.MaxUint64
+	}
+	ordered := func(f float32) int64 {
+		bits := int64(math.Float32bits(f))
+		// Map the sign-magnitude representation onto a monotonic integer line
+		if bits&0x80000000 != 0 {
+			return 0x80000000 - bits
+		}
+		return bits
+	}
+	diff := ordered(a) - ordered(b)
+	if diff < 0 {
+		diff = -diff
+	}
+	return uint64(diff)
+}
+
+// Matched reports whether every prediction is exact or within tolerance
+func (r *ParityResult) Matched() bool {
+	return r.Diff == 0
+}
+
+// Worst returns up to n samples with the largest distance, worst first.
+// NaN distances rank above every number.
+func (r *ParityResult) Worst(n int) []SampleParity {
+	worst := make([]SampleParity, len(r.Samples))
+	copy(worst, r.Samples)
+	sort.SliceStable(worst, func(i, j int) bool {
+		return largerDistance(worst[i].Distance, worst[j].Distance)
+	})
+	if n < len(worst) {
+		worst = worst[:n]
+	}
+	return worst
+}
+
+// largerDistance orders distances descending with NaN first, for sorting
+func largerDistance(a float64, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && !math.IsNaN(b)
+	}
+	return a > b
+}
+
+// ToleranceString formats the tolerance with its unit
+func (r *ParityResult) ToleranceString() string {
+	if r.Mode == ULPComparison {
+		return fmt.Sprintf("%g ULP", r.Tolerance)
+	}
+	return fmt.Sprintf("%g %s", r.Tolerance, r.Mode)
+}
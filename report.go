@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"math"
+)
+
+// Validation verdicts
+const (
+	VerdictExact = "exact"
+	VerdictClose = "close"
+	VerdictFail  = "fail"
+)
+
+// ValidationReport is the machine-readable result of the validate command
+type ValidationReport struct {
+	Model      string               `json:"model"`
+	TaskType   string               `json:"task_type"`
+	Verdict    string               `json:"verdict"`
+	Comparison ReportComparison     `json:"comparison"`
+	Counts     ReportCounts         `json:"counts"`
+	Metrics    []ReportMetricColumn `json:"metrics"`
+	Samples    []ReportSample       `json:"samples"`
+}
+
+// ReportComparison describes how predictions were compared
+type ReportComparison struct {
+	Mode      ComparisonMode `json:"mode"`
+	Tolerance float64        `json:"tolerance"`
+}
+
+// ReportCounts holds the number of samples per match status
+type ReportCounts struct {
+	Total int `json:"total"`
+	Exact int `json:"exact"`
+	Close int `json:"close"`
+	Diff  int `json:"diff"`
+}
+
+// ReportMetricColumn is one set of metrics, e.g. weighted or unweighted
+type ReportMetricColumn struct {
+	Title   string         `json:"title"`
+	Metrics []ReportMetric `json:"metrics"`
+}
+
+// ReportMetric is a metric value; undefined (NaN) values are null
+type ReportMetric struct {
+	Name  string   `json:"name"`
+	Label string   `json:"label"`
+	Value *float64 `json:"value"`
+}
+
+// ReportSample is the comparison result for one model output. Index is the
+// position of the output, Sample the validation sample it belongs to and
+// Class its class for multiclass models. Non-finite values are null.
+type ReportSample struct {
+	Index     int            `json:"index"`
+	Sample    int            `json:"sample"`
+	Class     *int           `json:"class,omitempty"`
+	Features  ValidationData `json:"features,omitempty"`
+	Predicted *float64       `json:"predicted"`
+	Expected  *float64       `json:"expected"`
+	Error     *float64       `json:"error"`
+	Distance  *float64       `json:"distance"`
+	Status    string         `json:"status"`
+}
+
+// buildValidationReport collects parity results and metrics into a report
+func buildValidationReport(modelPath string, torchData *TorchModelData, parity *ParityResult, columns []MetricColumn) *ValidationReport {
+	report := &ValidationReport{
+		Model:      modelPath,
+		TaskType:   torchData.TaskType,
+		Verdict:    parityVerdict(parity),
+		Comparison: ReportComparison{Mode: parity.Mode, Tolerance: parity.Tolerance},
+		Counts: ReportCounts{
+			Total: len(parity.Samples),
+			Exact: parity.Exact,
+			Close: parity.Close,
+			Diff:  parity.Diff,
+		},
+	}
+
+	for _, column := range columns {
+		reportColumn := ReportMetricColumn{Title: column.Title}
+		for _, metric := range column.Metrics {
+			reportMetric := ReportMetric{Name: metric.Name, Label: metric.Label, Value: finiteValue(metric.Value)}
+			reportColumn.Metrics = append(reportColumn.Metrics, reportMetric)
+		}
+		report.Metrics = append(report.Metrics, reportColumn)
+	}
+
+	// Multiclass models produce several scores per sample
+	scoresPerSample := 1
+	if samples := torchData.ValidationData; len(samples) > 0 && len(parity.Samples) > len(samples) {
+		scoresPerSample = len(parity.Samples) / len(samples)
+	}
+	for _, result := range parity.Samples {
+		sample := ReportSample{
+			Index:     result.Index,
+			Sample:    result.Index / scoresPerSample,
+			Predicted: finiteValue(result.Predicted),
+			Expected:  finiteValue(result.Expected),
+			Error:     finiteValue(result.Error),
+			Distance:  finiteValue(result.Distance),
+			Status:    result.Status,
+		}
+		if scoresPerSample > 1 {
+			class := result.Index % scoresPerSample
+			sample.Class = &class
+		}
+		if sample.Sample < len(torchData.ValidationData) {
+			sample.Features = torchData.ValidationData[sample.Sample]
+		}
+		report.Samples = append(report.Samples, sample)
+	}
+
+	return report
+}
+
+// finiteValue returns a pointer to v, or nil when v is NaN or infinite,
+// which JSON cannot represent
+func finiteValue(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+	return &v
+}
+
+// formatReportValue formats a report value, writing null for nil
+func formatReportValue(v *float64) string {
+	if v == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%g", *v)
+}
+
+// parityVerdict classifies a parity result as exact, close or fail
+func parityVerdict(parity *ParityResult) string {
+	switch {
+	case !parity.Matched():
+		return VerdictFail
+	case parity.Exact == len(parity.Samples):
+		return VerdictExact
+	default:
+		return VerdictClose
+	}
+}
+
+// writeJSONReport writes the report as indented JSON
+func writeJSONReport(filePath string, report *ValidationReport) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON report: %w", err)
+	}
+	if err := ioutil.WriteFile(filePath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write JSON report: %w", err)
+	}
+	return nil
+}
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite is a suite of test cases with its counts and properties
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Cases      []junitTestCase `xml:"testcase"`
+}
+
+// junitProperty is a name/value pair attached to a suite
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitTestCase is one validated sample
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitFailure marks a test case whose sample failed parity
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// writeJUnitReport writes the report as JUnit XML with one test case per
+// sample, so CI systems show each parity failure
+func writeJUnitReport(filePath string, report *ValidationReport) error {
+	suite := junitTestSuite{
+		Name:  "parity",
+		Tests: len(report.Samples),
+		Properties: []junitProperty{
+			{Name: "model", Value: report.Model},
+			{Name: "task_type", Value: report.TaskType},
+			{Name: "verdict", Value: report.Verdict},
+			{Name: "comparison", Value: string(report.Comparison.Mode)},
+			{Name: "tolerance", Value: fmt.Sprintf("%g", report.Comparison.Tolerance)},
+		},
+	}
+	for _, column := range report.Metrics {
+		for _, metric := range column.Metrics {
+			suite.Properties = append(suite.Properties, junitProperty{
+				Name:  fmt.Sprintf("%s.%s", column.Title, metric.Name),
+				Value: formatReportValue(metric.Value),
+			})
+		}
+	}
+
+	for _, sample := range report.Samples {
+		name := fmt.Sprintf("sample_%d", sample.Sample+1)
+		if sample.Class != nil {
+			name = fmt.Sprintf("%s_class_%d", name, *sample.Class)
+		}
+		predicted, expected, distance := formatReportValue(sample.Predicted), formatReportValue(sample.Expected), formatReportValue(sample.Distance)
+		testCase := junitTestCase{
+			Name:      name,
+			ClassName: "parity",
+			SystemOut: fmt.Sprintf("predicted=%s expected=%s error=%s distance=%s status=%s",
+				predicted, expected, formatReportValue(sample.Error), distance, sample.Status),
+		}
+		if sample.Status == MatchDiff {
+			suite.Failures++
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("predicted %s, expected %s (distance %s > %g %s)",
+					predicted, expected, distance, report.Comparison.Tolerance, report.Comparison.Mode),
+				Type: "ParityMismatch",
+			}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JUnit report: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+	if err := ioutil.WriteFile(filePath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// CurrentSchemaVersion is the newest artifact schema version this tool reads,
+// and the version the migrate command writes.
+//
+// Artifacts declare their version in the inner schema_version field.
+// Unversioned artifacts predate the field and are read by layout:
+//   - with feature_info.feature_names, as version 1, the layout this tool was
+//     written for
+//   - without it, as the legacy exporter layout, which gives the feature
+//     order only through the key order of categorical_vocab_sizes or through
+//     numerical_columns and categorical_columns lists, and may store label
+//     encoders under feature_info.label_encoders or
+//     missing_value_handling.encoders with their classes named categories or
+//     classes_
+const CurrentSchemaVersion = 1
+
+// schemaHeader holds the inner artifact fields read before the rest, so a
+// future layout is reported as such rather than as a decoding error
+type schemaHeader struct {
+	SchemaVersion int             `json:"schema_version"`
+	FeatureInfo   json.RawMessage `json:"feature_info"`
+}
+
+// checkSchemaVersion refuses schema versions this tool cannot read
+func checkSchemaVersion(version int) error {
+	switch {
+	case version < 0:
+		return fmt.Errorf("artifact schema version %d is invalid", version)
+	case version > CurrentSchemaVersion:
+		return fmt.Errorf("artifact schema version %d is newer than the newest version this tool reads (%d); upgrade go-torch-demo to load it", version, CurrentSchemaVersion)
+	}
+	return nil
+}
+
+// unmarshalTorchModelData decodes the inner artifact JSON, checking its
+// schema version and migrating a legacy layout into the current types
+func unmarshalTorchModelData(data []byte) (*TorchModelData, error) {
+	var header schemaHeader
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+	if err := checkSchemaVersion(header.SchemaVersion); err != nil {
+		return nil, err
+	}
+
+	var torchData TorchModelData
+	if err := json.Unmarshal(data, &torchData); err != nil {
+		return nil, err
+	}
+	extra, err := extraFields(data)
+	if err != nil {
+		return nil, err
+	}
+	torchData.Extra = extra
+	if err := applyFeatureInfoMigration(&torchData, header.FeatureInfo); err != nil {
+		return nil, err
+	}
+	return &torchData, nil
+}
+
+// extraFields returns the members of the inner artifact object that
+// TorchModelData has no field for, leaving out the keys in ignore
+func extraFields(data []byte, ignore ...string) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for _, key := range ignore {
+		known[key] = true
+	}
+	dataType := reflect.TypeOf(TorchModelData{})
+	for i := 0; i < dataType.NumField(); i++ {
+		name, _, _ := strings.Cut(dataType.Field(i).Tag.Get("json"), ",")
+		known[name] = true
+	}
+
+	var extra map[string]json.RawMessage
+	for key, value := range fields {
+		if known[key] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[key] = value
+	}
+	return extra, nil
+}
+
+// applyFeatureInfoMigration replaces torchData.FeatureInfo with the migrated
+// form of the raw feature_info when its layout is a legacy one
+func applyFeatureInfoMigration(torchData *TorchModelData, featureInfo json.RawMessage) error {
+	if len(featureInfo) == 0 {
+		return nil
+	}
+	migrated, changed, err := migrateFeatureInfo(featureInfo, torchData.SchemaVersion)
+	if err != nil {
+		return fmt.Errorf("feature_info: %w", err)
+	}
+	if !changed {
+		return nil
+	}
+	torchData.FeatureInfo = FeatureInfo{}
+	if err := json.Unmarshal(migrated, &torchData.FeatureInfo); err != nil {
+		return fmt.Errorf("feature_info: %w", err)
+	}
+	torchData.LegacyLayout = true
+	return nil
+}
+
+// migrateFeatureInfo rewrites a raw feature_info in the current layout. It
+// reports whether anything changed; feature_info of a declared version is
+// returned as is.
+func migrateFeatureInfo(raw json.RawMessage, version int) (json.RawMessage, bool, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+		return raw, false, nil
+	}
+	if _, exists := fields["feature_names"]; version > 0 || exists {
+		return raw, false, nil
+	}
+
+	numerical := []string{}
+	if columns, exists := fields["numerical_columns"]; exists {
+		if err := json.Unmarshal(columns, &numerical); err != nil {
+			return nil, false, fmt.Errorf("numerical_columns: %w", err)
+		}
+	} else {
+		var count int
+		if value, exists := fields["num_numerical_features"]; exists {
+			if err := json.Unmarshal(value, &count); err != nil {
+				return nil, false, fmt.Errorf("num_numerical_features: %w", err)
+			}
+		}
+		if count > 0 {
+			return nil, false, fmt.Errorf("legacy layout declares %d numerical features but lists no numerical_columns; the feature order cannot be recovered", count)
+		}
+	}
+
+	encoders, err := migrateLabelEncoders(fields)
+	if err != nil {
+		return nil, false, err
+	}
+
+	categorical := []string{}
+	if columns, exists := fields["categorical_columns"]; exists {
+		if err := json.Unmarshal(columns, &categorical); err != nil {
+			return nil, false, fmt.Errorf("categorical_columns: %w", err)
+		}
+	} else if vocabSizes, exists := fields["categorical_vocab_sizes"]; exists {
+		// The legacy exporter wrote vocabulary sizes in feature order
+		if categorical, err = objectKeys(vocabSizes); err != nil {
+			return nil, false, fmt.Errorf("categorical_vocab_sizes: %w", err)
+		}
+	} else if len(encoders) > 0 {
+		return nil, false, fmt.Errorf("legacy layout lists neither categorical_columns nor categorical_vocab_sizes; the feature order cannot be recovered")
+	}
+	delete(fields, "numerical_columns")
+	delete(fields, "categorical_columns")
+
+	if _, exists := fields["categorical_vocab_sizes"]; !exists {
+		vocabSizes := make(map[string]int, len(categorical))
+		for _, featureName := range categorical {
+			if encoder, exists := encoders[featureName]; exists {
+				vocabSizes[featureName] = len(encoder.Classes)
+			}
+		}
+		if fields["categorical_vocab_sizes"], err = marshalJSON(vocabSizes, ""); err != nil {
+			return nil, false, err
+		}
+	}
+	counts := map[string]int{"num_numerical_features": len(numerical), "num_categorical_features": len(categorical)}
+	for key, count := range counts {
+		if _, exists := fields[key]; !exists {
+			if fields[key], err = marshalJSON(count, ""); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	featureNames := map[string][]string{"numerical": numerical, "categorical": categorical}
+	if fields["feature_names"], err = marshalJSON(featureNames, ""); err != nil {
+		return nil, false, err
+	}
+
+	migrated, err := marshalJSON(fields, "")
+	return migrated, true, err
+}
+
+// migrateLabelEncoders moves legacy label encoders into
+// missing_value_handling.label_encoders, renaming their class lists to
+// classes, and returns them
+func migrateLabelEncoders(fields map[string]json.RawMessage) (map[string]LabelEncoder, error) {
+	handling := make(map[string]json.RawMessage)
+	if raw, exists := fields["missing_value_handling"]; exists {
+		if err := json.Unmarshal(raw, &handling); err != nil {
+			return nil, fmt.Errorf("missing_value_handling: %w", err)
+		}
+		if handling == nil {
+			handling = make(map[string]json.RawMessage)
+		}
+	}
+
+	source, exists := handling["label_encoders"]
+	if !exists {
+		source, exists = handling["encoders"]
+	}
+	if !exists {
+		source = fields["label_encoders"]
+	}
+	delete(handling, "encoders")
+	delete(fields, "label_encoders")
+
+	var rawEncoders map[string]map[string]json.RawMessage
+	if source != nil {
+		if err := json.Unmarshal(source, &rawEncoders); err != nil {
+			return nil, fmt.Errorf("label encoders: %w", err)
+		}
+	}
+	encoders := make(map[string]LabelEncoder, len(rawEncoders))
+	for featureName, rawEncoder := range rawEncoders {
+		for _, legacy := range []string{"categories", "classes_"} {
+			if _, exists := rawEncoder["classes"]; !exists && rawEncoder[legacy] != nil {
+				rawEncoder["classes"] = rawEncoder[legacy]
+			}
+			delete(rawEncoder, legacy)
+		}
+		data, err := marshalJSON(rawEncoder, "")
+		if err != nil {
+			return nil, err
+		}
+		var encoder LabelEncoder
+		if err := json.Unmarshal(data, &encoder); err != nil {
+			return nil, fmt.Errorf("label encoder of %s: %w", featureName, err)
+		}
+		encoders[featureName] = encoder
+	}
+
+	var err error
+	if handling["label_encoders"], err = marshalJSON(encoders, ""); err != nil {
+		return nil, err
+	}
+	if fields["missing_value_handling"], err = marshalJSON(handling, ""); err != nil {
+		return nil, err
+	}
+	return encoders, nil
+}
+
+// objectKeys returns the keys of a JSON object in document order
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("expected a JSON object")
+	}
+	keys := []string{}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, token.(string))
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// rawSchemaVersion returns the checked schema version of raw inner members
+func rawSchemaVersion(inner map[string]json.RawMessage) (int, error) {
+	var version int
+	if raw, exists := inner["schema_version"]; exists {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return 0, fmt.Errorf("schema_version: %w", err)
+		}
+	}
+	return version, checkSchemaVersion(version)
+}
+
+// runMigrate rewrites a model.json artifact in the current schema version,
+// keeping every value this tool does not migrate verbatim. Digests and
+// signatures stay valid, since they cover the migrated form.
+func runMigrate(args []string) {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	modelPath := flags.String("model", "data/model.json", "path to the model JSON file to migrate")
+	outPath := flags.String("out", "", "path of the migrated model JSON file")
+	flags.Parse(args)
+
+	if *outPath == "" {
+		log.Fatalf("Invalid flags: -out is required")
+	}
+	if isModelBundle(*modelPath) {
+		log.Fatalf("Only model JSON files can be migrated; model directories are read in any version")
+	}
+	artifact, err := readArtifactFile(*modelPath)
+	if err != nil {
+		log.Fatalf("Failed to read artifact: %v", err)
+	}
+	migrated, from, err := migrateArtifact(artifact)
+	if err != nil {
+		log.Fatalf("Failed to migrate %s: %v", *modelPath, err)
+	}
+	if err := writeArtifactFile(*outPath, migrated); err != nil {
+		log.Fatalf("Failed to write migrated artifact: %v", err)
+	}
+	fmt.Printf("Migrated %s from %s to schema version %d in %s\n", *modelPath, from, CurrentSchemaVersion, *outPath)
+}
+
+// migrateArtifact migrates a raw model.json artifact to the current schema
+// version and describes the layout it was migrated from
+func migrateArtifact(artifact []byte) ([]byte, string, error) {
+	outer, inner, err := parseRawArtifact(artifact)
+	if err != nil {
+		return nil, "", err
+	}
+	version, err := rawSchemaVersion(inner)
+	if err != nil {
+		return nil, "", err
+	}
+
+	from := fmt.Sprintf("schema version %d", version)
+	if version == 0 {
+		from = "an unversioned layout"
+	}
+	if featureInfo, exists := inner["feature_info"]; exists {
+		migrated, changed, err := migrateFeatureInfo(featureInfo, version)
+		if err != nil {
+			return nil, "", fmt.Errorf("feature_info: %w", err)
+		}
+		if changed {
+			inner["feature_info"] = migrated
+			from = "the legacy layout"
+		}
+	} else if _, encrypted := inner["encrypted_feature_info"]; encrypted && version == 0 {
+		return nil, "", fmt.Errorf("feature_info is encrypted; migrate the artifact before encrypting it")
+	}
+
+	if inner["schema_version"], err = marshalJSON(CurrentSchemaVersion, ""); err != nil {
+		return nil, "", err
+	}
+	encoded, err := encodeRawArtifact(outer, inner)
+	return encoded, from, err
+}
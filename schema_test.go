@@ -0,0 +1,145 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMigrateFeatureInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		featureInfo string
+		numerical   []string
+		categorical []string
+		vocabSizes  map[string]int
+		classes     map[string][]string
+		legacy      bool
+	}{
+		{
+			name:        "current layout is kept",
+			featureInfo: `{"num_numerical_features": 1, "num_categorical_features": 1, "feature_names": {"numerical": ["age"], "categorical": ["geo"]}, "categorical_vocab_sizes": {"geo": 2}, "missing_value_handling": {"label_encoders": {"geo": {"classes": ["DE", "US"]}}}}`,
+			numerical:   []string{"age"},
+			categorical: []string{"geo"},
+			vocabSizes:  map[string]int{"geo": 2},
+			classes:     map[string][]string{"geo": {"DE", "US"}},
+		},
+		{
+			name:        "classes_ under missing_value_handling.label_encoders",
+			featureInfo: `{"categorical_columns": ["geo"], "missing_value_handling": {"label_encoders": {"geo": {"classes_": ["DE", "US"]}}}}`,
+			numerical:   []string{},
+			categorical: []string{"geo"},
+			vocabSizes:  map[string]int{"geo": 2},
+			classes:     map[string][]string{"geo": {"DE", "US"}},
+			legacy:      true,
+		},
+		{
+			name:        "categories under missing_value_handling.encoders",
+			featureInfo: `{"categorical_columns": ["geo", "os"], "missing_value_handling": {"encoders": {"geo": {"categories": ["DE", "FR", "US"]}, "os": {"categories": ["ios"]}}}}`,
+			numerical:   []string{},
+			categorical: []string{"geo", "os"},
+			vocabSizes:  map[string]int{"geo": 3, "os": 1},
+			classes:     map[string][]string{"geo": {"DE", "FR", "US"}, "os": {"ios"}},
+			legacy:      true,
+		},
+		{
+			name:        "label_encoders at the top level",
+			featureInfo: `{"categorical_columns": ["geo"], "label_encoders": {"geo": {"classes_": ["DE", "US"], "dtype": "object"}}}`,
+			numerical:   []string{},
+			categorical: []string{"geo"},
+			vocabSizes:  map[string]int{"geo": 2},
+			classes:     map[string][]string{"geo": {"DE", "US"}},
+			legacy:      true,
+		},
+		{
+			name:        "column lists",
+			featureInfo: `{"numerical_columns": ["price", "age"], "categorical_columns": ["os", "geo"], "categorical_vocab_sizes": {"geo": 2, "os": 1}, "label_encoders": {"geo": {"classes": ["DE", "US"]}, "os": {"classes": ["ios"]}}}`,
+			numerical:   []string{"price", "age"},
+			categorical: []string{"os", "geo"},
+			vocabSizes:  map[string]int{"geo": 2, "os": 1},
+			classes:     map[string][]string{"geo": {"DE", "US"}, "os": {"ios"}},
+			legacy:      true,
+		},
+		{
+			name:        "categorical_vocab_sizes key order",
+			featureInfo: `{"categorical_vocab_sizes": {"zone": 3, "app": 2, "geo": 4}}`,
+			numerical:   []string{},
+			categorical: []string{"zone", "app", "geo"},
+			vocabSizes:  map[string]int{"zone": 3, "app": 2, "geo": 4},
+			classes:     map[string][]string{},
+			legacy:      true,
+		},
+	}
+	for _, tc := range tests {
+		torchData, err := unmarshalTorchModelData([]byte(`{"feature_info": ` + tc.featureInfo + `}`))
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		featureInfo := torchData.FeatureInfo
+		if torchData.LegacyLayout != tc.legacy {
+			t.Errorf("%s: legacy layout = %t, want %t", tc.name, torchData.LegacyLayout, tc.legacy)
+		}
+		if got := featureInfo.FeatureNames["numerical"]; !reflect.DeepEqual(got, tc.numerical) {
+			t.Errorf("%s: numerical features = %q, want %q", tc.name, got, tc.numerical)
+		}
+		if got := featureInfo.FeatureNames["categorical"]; !reflect.DeepEqual(got, tc.categorical) {
+			t.Errorf("%s: categorical features = %q, want %q", tc.name, got, tc.categorical)
+		}
+		if featureInfo.NumNumericalFeatures != len(tc.numerical) || featureInfo.NumCategoricalFeatures != len(tc.categorical) {
+			t.Errorf("%s: feature counts = %d numerical, %d categorical, want %d, %d", tc.name,
+				featureInfo.NumNumericalFeatures, featureInfo.NumCategoricalFeatures, len(tc.numerical), len(tc.categorical))
+		}
+		if !reflect.DeepEqual(featureInfo.CategoricalVocabSizes, tc.vocabSizes) {
+			t.Errorf("%s: vocabulary sizes = %v, want %v", tc.name, featureInfo.CategoricalVocabSizes, tc.vocabSizes)
+		}
+		classes := make(map[string][]string)
+		for featureName, encoder := range featureInfo.MissingValueHandling.LabelEncoders {
+			classes[featureName] = encoder.Classes
+		}
+		if !reflect.DeepEqual(classes, tc.classes) {
+			t.Errorf("%s: label encoder classes = %q, want %q", tc.name, classes, tc.classes)
+		}
+	}
+}
+
+func TestMigrateFeatureInfoErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		inner string
+		want  string
+	}{
+		{
+			name:  "future schema version",
+			inner: `{"schema_version": 2, "feature_info": {"feature_names": {"numerical": [], "categorical": []}}}`,
+			want:  "newer than the newest version",
+		},
+		{
+			name:  "negative schema version",
+			inner: `{"schema_version": -1, "feature_info": {}}`,
+			want:  "is invalid",
+		},
+		{
+			name:  "numerical features without columns",
+			inner: `{"feature_info": {"num_numerical_features": 2, "categorical_columns": []}}`,
+			want:  "feature order cannot be recovered",
+		},
+		{
+			name:  "encoders without a feature order",
+			inner: `{"feature_info": {"label_encoders": {"geo": {"classes": ["DE"]}}}}`,
+			want:  "feature order cannot be recovered",
+		},
+	}
+	for _, tc := range tests {
+		_, err := unmarshalTorchModelData([]byte(tc.inner))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error = %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+
+	// Decoding into FeatureInfo would fail first, so migrate directly
+	_, _, err := migrateFeatureInfo([]byte(`{"num_numerical_features": "two", "categorical_columns": []}`), 0)
+	if err == nil || !strings.Contains(err.Error(), "num_numerical_features") {
+		t.Errorf("malformed numerical feature count: error = %v, want one naming num_numerical_features", err)
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// sliceMissingValue labels samples that do not carry a slicing feature
+const sliceMissingValue = "<missing>"
+
+// SliceStats holds the metrics and parity stats of one segment of samples
+type SliceStats struct {
+	Key         string
+	Count       int
+	Weight      float64
+	Metrics     []Metric
+	Primary     float64
+	ParityDiff  int
+	MaxDistance float64
+}
+
+// parseSliceSpecs parses a comma-separated list of slicing features, where
+// "a:b" slices by the cross of two features. Every feature must be categorical.
+func parseSliceSpecs(value string, featureInfo FeatureInfo) ([][]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	categorical := make(map[string]bool)
+	for _, featureName := range featureInfo.FeatureNames["categorical"] {
+		categorical[featureName] = true
+	}
+
+	var specs [][]string
+	for _, entry := range strings.Split(value, ",") {
+		features := strings.Split(strings.TrimSpace(entry), ":")
+		if len(features) > 2 {
+			return nil, fmt.Errorf("slice %q crosses more than two features", entry)
+		}
+		for _, featureName := range features {
+			if !categorical[featureName] {
+				return nil, fmt.Errorf("slice feature %q is not a categorical feature", featureName)
+			}
+		}
+		specs = append(specs, features)
+	}
+	return specs, nil
+}
+
+// primaryMetricName returns the metric used to rank slices, where higher is worse
+func primaryMetricName(taskType string) string {
+	if task, err := normalizeTaskType(taskType); err == nil && task != TaskRegression {
+		return "log_loss"
+	}
+	return "rmse"
+}
+
+// computeSlices groups samples by the values of the spec's features and
+// computes metrics per group, reading every slice's scores with the same
+// scale. predictions may hold several scores per sample (multiclass).
+// parity, if not nil, must be aligned with the samples.
+func computeSlices(spec []string, samples []ValidationData, taskType string, scale ScoreScale, predictions []float64, targets []float64, weights []float64, parity *ParityResult) ([]SliceStats, error) {
+	if len(samples) == 0 || len(targets) != len(samples) {
+		return nil, fmt.Errorf("got %d targets for %d samples", len(targets), len(samples))
+	}
+	scoresPerSample := len(predictions) / len(samples)
+
+	groups := make(map[string][]int)
+	var keys []string
+	for i, sample := range samples {
+		parts := make([]string, len(spec))
+		for j, featureName := range spec {
+			value, exists := sample[featureName]
+			if exists {
+				parts[j] = fmt.Sprintf("%s=%v", featureName, value)
+			} else {
+				parts[j] = fmt.Sprintf("%s=%s", featureName, sliceMissingValue)
+			}
+		}
+		key := strings.Join(parts, ", ")
+		if _, exists := groups[key]; !exists {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], i)
+	}
+
+	primaryName := primaryMetricName(taskType)
+	slices := make([]SliceStats, 0, len(keys))
+	for _, key := range keys {
+		indices := groups[key]
+		slicePredictions := make([]float64, 0, len(indices)*scoresPerSample)
+		sliceTargets := make([]float64, 0, len(indices))
+		var sliceWeights []float64
+
+		stats := SliceStats{Key: key, Count: len(indices)}
+		for _, i := range indices {
+			slicePredictions = append(slicePredictions, predictions[i*scoresPerSample:(i+1)*scoresPerSample]...)
+			sliceTargets = append(sliceTargets, targets[i])
+			if weights != nil {
+				sliceWeights = append(sliceWeights, weights[i])
+				stats.Weight += weights[i]
+			} else {
+				stats.Weight++
+			}
+			if parity != nil {
+				for _, result := range parity.Samples[i*scoresPerSample : (i+1)*scoresPerSample] {
+					if result.Status == MatchDiff {
+						stats.ParityDiff++
+					}
+					stats.MaxDistance = math.Max(stats.MaxDistance, result.Distance)
+				}
+			}
+		}
+
+		stats.Primary = math.NaN()
+		// A slice whose weights sum to zero has no defined metrics
+		if stats.Weight > 0 {
+			metrics, err := computeMetrics(taskType, scale, slicePredictions, sliceTargets, sliceWeights)
+			if err != nil {
+				return nil, fmt.Errorf("slice %s: %w", key, err)
+			}
+			stats.Metrics = metrics
+			for _, metric := range metrics {
+				if metric.Name == primaryName {
+					stats.Primary = metric.Value
+				}
+			}
+		}
+		slices = append(slices, stats)
+	}
+
+	return slices, nil
+}
+
+// worstSlices sorts slices worst first, by parity failures and then by the
+// primary metric, keeping those with at least minCount samples
+func worstSlices(slices []SliceStats, minCount int) []SliceStats {
+	var kept []SliceStats
+	for _, slice := range slices {
+		if slice.Count >= minCount {
+			kept = append(kept, slice)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		if kept[i].ParityDiff != kept[j].ParityDiff {
+			return kept[i].ParityDiff > kept[j].ParityDiff
+		}
+		if math.IsNaN(kept[j].Primary) {
+			return !math.IsNaN(kept[i].Primary)
+		}
+		return kept[i].Primary > kept[j].Primary
+	})
+	return kept
+}
+
+// printSlices prints the worst slices of one slicing spec
+func printSlices(title string, spec []string, slices []SliceStats, taskType string, withParity bool, top int, minCount int) {
+	primaryName := primaryMetricName(taskType)
+	ranked := worstSlices(slices, minCount)
+
+	shown := ranked
+	if top > 0 && len(shown) > top {
+		shown = shown[:top]
+	}
+
+	fmt.Printf("\n=== %s by %s (%d slices, worst %d by %s) ===\n",
+		title, strings.Join(spec, " x "), len(ranked), len(shown), primaryName)
+	fmt.Printf("%-48s %-8s %-12s %-16s", "Slice", "Count", "Weight", primaryName)
+	if withParity {
+		fmt.Printf(" %-12s %-14s", "Parity DIFF", "Max distance")
+	}
+	fmt.Printf("\n%s\n", strings.Repeat("-", 120))
+
+	for _, slice := range shown {
+		fmt.Printf("%-48s %-8d %-12.4f %-16.6f", truncateString(slice.Key, 48), slice.Count, slice.Weight, slice.Primary)
+		if withParity {
+			fmt.Printf(" %-12d %-14.6g", slice.ParityDiff, slice.MaxDistance)
+		}
+		fmt.Printf("\n")
+	}
+}
@@ -0,0 +1,456 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// loadModelDataStream decodes an artifact from r in a single pass. The
+// nested Data string is unescaped on the fly and the base64 model payload is
+// decoded straight into TorchModelData.ModelBytes, so neither the outer JSON,
+// the inner JSON string nor the base64 text is ever held in memory.
+// ModelData.Data and TorchModel.Model are left empty. sizeHint, if positive,
+// is the artifact size in bytes and is used to size the model buffer.
+func loadModelDataStream(r io.Reader, sizeHint int64) (*ModelData, *TorchModelData, error) {
+	outer := newJSONScanner(r)
+	var modelData ModelData
+	var torchData *TorchModelData
+
+	err := outer.object(func(key string) error {
+		switch key {
+		case "interval":
+			var raw bytes.Buffer
+			if err := outer.rawValue(&raw); err != nil {
+				return err
+			}
+			return json.Unmarshal(raw.Bytes(), &modelData.Interval)
+		case "data":
+			data, err := outer.stringValue()
+			if err != nil {
+				return err
+			}
+			torchData, err = decodeTorchModelData(data, sizeHint)
+			if err != nil {
+				return fmt.Errorf("failed to parse inner JSON: %w", err)
+			}
+			return nil
+		default:
+			return outer.rawValue(nil)
+		}
+	})
+	if err == nil {
+		err = outer.end()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if torchData == nil {
+		return nil, nil, fmt.Errorf("artifact has no data field")
+	}
+	return &modelData, torchData, nil
+}
+
+// decodeTorchModelData decodes the inner artifact JSON, streaming the base64
+// model payload into ModelBytes and buffering only the remaining metadata.
+// A compressed payload is decompressed once the whole object is read, since
+// the compression field may follow the model; an encrypted one is kept in
+// EncryptedModel for decryptArtifact.
+func decodeTorchModelData(r io.Reader, sizeHint int64) (*TorchModelData, error) {
+	inner := newJSONScanner(r)
+	fields := newRawObject()
+	var modelBytes []byte
+
+	err := inner.object(func(key string) error {
+		if key != "torch_model" {
+			return fields.add(key, inner)
+		}
+
+		torchModelFields := newRawObject()
+		err := inner.object(func(key string) error {
+			if key != "model" {
+				return torchModelFields.add(key, inner)
+			}
+			payload, err := inner.stringValue()
+			if err != nil {
+				return err
+			}
+			buffer := bytes.NewBuffer(make([]byte, 0, modelBufferSize(sizeHint)))
+			if _, err := io.Copy(buffer, base64.NewDecoder(base64.StdEncoding, payload)); err != nil {
+				return fmt.Errorf("failed to decode model: %w", err)
+			}
+			modelBytes = buffer.Bytes()
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		fields.addRaw(key, torchModelFields.bytes())
+		return nil
+	})
+	if err == nil {
+		err = inner.end()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	torchData, err := unmarshalTorchModelData(fields.bytes())
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case modelBytes == nil:
+	case torchData.Encryption != nil && torchData.Encryption.Model:
+		torchData.EncryptedModel = modelBytes
+	default:
+		torchData.ModelBytes, err = decompressModel(modelBytes, torchData.TorchModel.Compression)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return torchData, nil
+}
+
+// modelBufferSize estimates the decoded model size from the artifact size:
+// base64 takes 4 bytes per 3 and the model dominates the artifact
+func modelBufferSize(sizeHint int64) int {
+	if sizeHint <= 0 {
+		return 0
+	}
+	return int(sizeHint / 4 * 3)
+}
+
+// rawObject rebuilds a JSON object from raw member values
+type rawObject struct {
+	buffer bytes.Buffer
+}
+
+func newRawObject() *rawObject {
+	o := &rawObject{}
+	o.buffer.WriteByte('{')
+	return o
+}
+
+// add copies the next value from the scanner as the member key
+func (o *rawObject) add(key string, scanner *jsonScanner) error {
+	o.writeKey(key)
+	return scanner.rawValue(&o.buffer)
+}
+
+// addRaw adds an already encoded value as the member key
+func (o *rawObject) addRaw(key string, value []byte) {
+	o.writeKey(key)
+	o.buffer.Write(value)
+}
+
+func (o *rawObject) writeKey(key string) {
+	if o.buffer.Len() > 1 {
+		o.buffer.WriteByte(',')
+	}
+	o.buffer.WriteString(strconv.Quote(key))
+	o.buffer.WriteByte(':')
+}
+
+// bytes returns the encoded object
+func (o *rawObject) bytes() []byte {
+	return append(o.buffer.Bytes(), '}')
+}
+
+// jsonScanner is a minimal pull parser for walking JSON objects and
+// streaming string values without materializing them
+type jsonScanner struct {
+	r *bufio.Reader
+}
+
+func newJSONScanner(r io.Reader) *jsonScanner {
+	return &jsonScanner{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// next returns the next non-whitespace byte
+func (s *jsonScanner) next() (byte, error) {
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		return c, nil
+	}
+}
+
+// expect consumes the next non-whitespace byte, which must be want
+func (s *jsonScanner) expect(want byte) error {
+	c, err := s.next()
+	if err != nil {
+		return err
+	}
+	if c != want {
+		return fmt.Errorf("expected %q, found %q", want, c)
+	}
+	return nil
+}
+
+// end checks that only whitespace is left after the top-level value
+func (s *jsonScanner) end() error {
+	for {
+		c, err := s.r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r':
+			continue
+		}
+		return fmt.Errorf("invalid character %q after top-level value", c)
+	}
+}
+
+// object walks the members of the next object, calling member with each key.
+// member must consume the member's value.
+func (s *jsonScanner) object(member func(key string) error) error {
+	if err := s.expect('{'); err != nil {
+		return err
+	}
+	c, err := s.next()
+	if err != nil {
+		return err
+	}
+	if c == '}' {
+		return nil
+	}
+	s.r.UnreadByte()
+
+	for {
+		if err := s.expect('"'); err != nil {
+			return err
+		}
+		key, err := io.ReadAll(&jsonStringReader{r: s.r})
+		if err != nil {
+			return err
+		}
+		if err := s.expect(':'); err != nil {
+			return err
+		}
+		if err := member(string(key)); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		c, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ',':
+			continue
+		case '}':
+			return nil
+		default:
+			return fmt.Errorf("expected ',' or '}' after %s, found %q", key, c)
+		}
+	}
+}
+
+// stringValue returns a reader over the unescaped contents of the next
+// value, which must be a string. The reader must be drained before the
+// scanner is used again.
+func (s *jsonScanner) stringValue() (io.Reader, error) {
+	if err := s.expect('"'); err != nil {
+		return nil, fmt.Errorf("expected a string: %w", err)
+	}
+	return &jsonStringReader{r: s.r}, nil
+}
+
+// rawValue copies the next value verbatim to w, or skips it if w is nil
+func (s *jsonScanner) rawValue(w *bytes.Buffer) error {
+	if w == nil {
+		w = &bytes.Buffer{}
+	}
+	c, err := s.next()
+	if err != nil {
+		return err
+	}
+
+	switch c {
+	case '"':
+		w.WriteByte(c)
+		return s.copyString(w)
+	case '{', '[':
+		w.WriteByte(c)
+		depth := 1
+		for depth > 0 {
+			c, err := s.r.ReadByte()
+			if err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			w.WriteByte(c)
+			switch c {
+			case '"':
+				if err := s.copyString(w); err != nil {
+					return err
+				}
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		return nil
+	default:
+		// Numbers and literals run until the next delimiter
+		w.WriteByte(c)
+		for {
+			c, err := s.r.ReadByte()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			switch c {
+			case ',', '}', ']', ' ', '\t', '\n', '\r':
+				return s.r.UnreadByte()
+			}
+			w.WriteByte(c)
+		}
+	}
+}
+
+// copyString copies the rest of a string, escapes included, through the
+// closing quote
+func (s *jsonScanner) copyString(w *bytes.Buffer) error {
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return io.ErrUnexpectedEOF
+		}
+		w.WriteByte(c)
+		switch c {
+		case '"':
+			return nil
+		case '\\':
+			c, err := s.r.ReadByte()
+			if err != nil {
+				return io.ErrUnexpectedEOF
+			}
+			w.WriteByte(c)
+		}
+	}
+}
+
+// jsonStringReader unescapes a JSON string whose opening quote has been
+// consumed, returning io.EOF at the closing quote
+type jsonStringReader struct {
+	r       *bufio.Reader
+	pending []byte
+	done    bool
+}
+
+func (s *jsonStringReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(s.pending) > 0 {
+			copied := copy(p[n:], s.pending)
+			s.pending = s.pending[copied:]
+			n += copied
+			continue
+		}
+		if s.done {
+			break
+		}
+
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return n, io.ErrUnexpectedEOF
+		}
+		switch {
+		case c == '"':
+			s.done = true
+		case c == '\\':
+			if err := s.unescape(); err != nil {
+				return n, err
+			}
+		case c < 0x20:
+			return n, fmt.Errorf("invalid control character %#x in string", c)
+		default:
+			p[n] = c
+			n++
+		}
+	}
+	if n == 0 && s.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// unescape decodes the escape sequence following a backslash into pending
+func (s *jsonStringReader) unescape() error {
+	c, err := s.r.ReadByte()
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	switch c {
+	case '"', '\\', '/':
+		s.pending = append(s.pending[:0], c)
+	case 'b':
+		s.pending = append(s.pending[:0], '\b')
+	case 'f':
+		s.pending = append(s.pending[:0], '\f')
+	case 'n':
+		s.pending = append(s.pending[:0], '\n')
+	case 'r':
+		s.pending = append(s.pending[:0], '\r')
+	case 't':
+		s.pending = append(s.pending[:0], '\t')
+	case 'u':
+		r, err := s.readHex()
+		if err != nil {
+			return err
+		}
+		if utf16.IsSurrogate(r) {
+			// A surrogate pair spans two escapes; a lone surrogate becomes U+FFFD
+			if next, err := s.r.Peek(2); err == nil && next[0] == '\\' && next[1] == 'u' {
+				s.r.Discard(2)
+				low, err := s.readHex()
+				if err != nil {
+					return err
+				}
+				r = utf16.DecodeRune(r, low)
+			} else {
+				r = utf8.RuneError
+			}
+		}
+		s.pending = utf8.AppendRune(s.pending[:0], r)
+	default:
+		return fmt.Errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+// readHex reads the four hex digits of a \u escape
+func (s *jsonStringReader) readHex() (rune, error) {
+	var digits [4]byte
+	if _, err := io.ReadFull(s.r, digits[:]); err != nil {
+		return 0, io.ErrUnexpectedEOF
+	}
+	value, err := strconv.ParseUint(string(digits[:]), 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid escape sequence \\u%s", digits[:])
+	}
+	return rune(value), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLoadModelDataStreamMatchesParse(t *testing.T) {
+	data, err := os.ReadFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modelData, torchData, err := parseModelData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The streaming loader decodes the payload and drops the raw strings
+	torchData.ModelBytes, err = decodeModelBytes(torchData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	torchData.TorchModel.Model = ""
+
+	streamedData, streamedTorchData, err := loadModelDataStream(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamedData.Interval != modelData.Interval {
+		t.Errorf("interval = %v, want %v", streamedData.Interval, modelData.Interval)
+	}
+	if !reflect.DeepEqual(streamedTorchData, torchData) {
+		t.Errorf("streamed artifact differs from parsed artifact")
+	}
+}
+
+func TestLoadModelDataStreamMalformed(t *testing.T) {
+	data, err := os.ReadFile("data/model.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.TrimSpace(data)
+
+	var outer struct {
+		Interval json.RawMessage `json:"interval"`
+		Data     string          `json:"data"`
+	}
+	if err := json.Unmarshal(data, &outer); err != nil {
+		t.Fatal(err)
+	}
+	withInner := func(inner string) []byte {
+		encoded, err := json.Marshal(map[string]interface{}{"interval": outer.Interval, "data": inner})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return encoded
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+		valid bool
+	}{
+		{"trailing whitespace", append(append([]byte{}, data...), " \n\t"...), true},
+		{"truncated in the model payload", data[:len(data)/2], false},
+		{"truncated before the closing brace", data[:len(data)-1], false},
+		{"empty", nil, false},
+		{"trailing garbage", append(append([]byte{}, data...), " x"...), false},
+		{"trailing object", append(append([]byte{}, data...), "{}"...), false},
+		{"trailing brace", append(append([]byte{}, data...), '}'), false},
+		{"inner trailing garbage", withInner(outer.Data + " x"), false},
+		{"inner truncated", withInner(outer.Data[:len(outer.Data)-1]), false},
+	}
+	for _, tc := range tests {
+		_, _, parseErr := parseModelData(tc.input)
+		_, _, streamErr := loadModelDataStream(bytes.NewReader(tc.input), int64(len(tc.input)))
+		if (parseErr == nil) != tc.valid {
+			t.Errorf("%s: parseModelData error = %v, want valid %v", tc.name, parseErr, tc.valid)
+		}
+		if (streamErr == nil) != tc.valid {
+			t.Errorf("%s: loadModelDataStream error = %v, want valid %v", tc.name, streamErr, tc.valid)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// featureColumns returns every model feature, categorical first, in
+// FeatureInfo order
+func featureColumns(featureInfo FeatureInfo) []string {
+	var columns []string
+	columns = append(columns, featureInfo.FeatureNames["categorical"]...)
+	columns = append(columns, featureInfo.FeatureNames["numerical"]...)
+	return columns
+}
+
+// selectTableColumns resolves a comma-separated column selection. "all"
+// selects every feature and "none" hides feature columns.
+func selectTableColumns(featureInfo FeatureInfo, selection string) ([]string, error) {
+	switch strings.TrimSpace(selection) {
+	case "", "all":
+		return featureColumns(featureInfo), nil
+	case "none":
+		return nil, nil
+	}
+
+	known := make(map[string]bool)
+	for _, featureName := range featureColumns(featureInfo) {
+		known[featureName] = true
+	}
+
+	var columns []string
+	for _, featureName := range strings.Split(selection, ",") {
+		featureName = strings.TrimSpace(featureName)
+		if !known[featureName] {
+			return nil, fmt.Errorf("unknown feature column %q", featureName)
+		}
+		columns = append(columns, featureName)
+	}
+	return columns, nil
+}
+
+// printFeatureSummary prints the vocabulary of every categorical feature
+// and the list of numerical features
+func printFeatureSummary(featureInfo FeatureInfo) {
+	fmt.Printf("\nFeature Mappings:\n")
+	for _, featureName := range featureInfo.FeatureNames["categorical"] {
+		encoder, exists := featureInfo.MissingValueHandling.LabelEncoders[featureName]
+		if !exists {
+			fmt.Printf("- %s: no label encoder\n", featureName)
+			continue
+		}
+		fmt.Printf("- %s: %d categories (indices 0-%d)\n", featureName, len(encoder.Classes), len(encoder.Classes)-1)
+	}
+	if numerical := featureInfo.FeatureNames["numerical"]; len(numerical) > 0 {
+		fmt.Printf("- Numerical: %s\n", strings.Join(numerical, ", "))
+	}
+}
+
+// printValidationTable prints one row per compared prediction with the
+// selected feature columns, each truncated to width characters
+func printValidationTable(samples []ValidationData, parity *ParityResult, columns []string, width int) {
+	// Multiclass models produce several scores per sample
+	scoresPerSample := 1
+	if len(samples) > 0 && len(parity.Samples) > len(samples) {
+		scoresPerSample = len(parity.Samples) / len(samples)
+	}
+
+	fmt.Printf("%-4s", "#")
+	for _, column := range columns {
+		fmt.Printf(" %-*s", width, truncateString(column, width))
+	}
+	fmt.Printf(" %-12s %-12s %-12s %-8s\n", "Predicted", "Expected", "Error", "Match")
+	fmt.Printf("%s\n", strings.Repeat("-", 4+(width+1)*len(columns)+48))
+
+	for i, result := range parity.Samples {
+		fmt.Printf("%-4d", i+1)
+		sampleIndex := i / scoresPerSample
+		for _, column := range columns {
+			value := ""
+			if sampleIndex < len(samples) {
+				if raw, exists := samples[sampleIndex][column]; exists {
+					value = fmt.Sprintf("%v", raw)
+				}
+			}
+			fmt.Printf(" %-*s", width, truncateString(value, width))
+		}
+		fmt.Printf(" %-12.6f %-12.6f %-12.6f %-8s\n", result.Predicted, result.Expected, result.Error, result.Status)
+	}
+}
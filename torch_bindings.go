@@ -13,6 +13,7 @@ typedef void* torch_tensor_t;
 // C wrapper functions - will link with actual libtorch
 extern torch_module_t load_torch_module_from_buffer(const char* buffer, long long size);
 extern void free_torch_module(torch_module_t module);
+extern char* get_module_parameter_shapes(torch_module_t module);
 extern torch_tensor_t create_tensor_from_data(float* data, long long* dims, int ndims);
 extern torch_tensor_t create_int_tensor_from_data(float* data, long long* dims, int ndims);
 extern torch_tensor_t forward_module(torch_module_t module, torch_tensor_t numerical_input, torch_tensor_t categorical_input);
@@ -24,6 +25,8 @@ import "C"
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"unsafe"
 )
 
@@ -75,6 +78,48 @@ func (m *TorchModule) Forward(numericalInput *TorchTensor, categoricalInput *Tor
 	return &TorchTensor{ptr: outputPtr}, nil
 }
 
+// ModuleParameter is a named parameter of a TorchScript module
+type ModuleParameter struct {
+	Name  string
+	Shape []int64
+}
+
+// Parameters lists the named parameters of the module and their shapes
+func (m *TorchModule) Parameters() ([]ModuleParameter, error) {
+	if m.ptr == nil {
+		return nil, fmt.Errorf("module is nil")
+	}
+
+	cDescription := C.get_module_parameter_shapes(m.ptr)
+	if cDescription == nil {
+		return nil, fmt.Errorf("failed to list module parameters")
+	}
+	defer C.free(unsafe.Pointer(cDescription))
+
+	var params []ModuleParameter
+	for _, line := range strings.Split(strings.TrimSpace(C.GoString(cDescription)), "\n") {
+		if line == "" {
+			continue
+		}
+		name, dims, found := strings.Cut(line, "\t")
+		if !found {
+			return nil, fmt.Errorf("malformed parameter description %q", line)
+		}
+		param := ModuleParameter{Name: name}
+		if dims != "" {
+			for _, dim := range strings.Split(dims, ",") {
+				size, err := strconv.ParseInt(dim, 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("malformed shape of parameter %s: %v", name, err)
+				}
+				param.Shape = append(param.Shape, size)
+			}
+		}
+		params = append(params, param)
+	}
+	return params, nil
+}
+
 // Free releases the module memory
 func (m *TorchModule) Free() {
 	if m.ptr != nil {
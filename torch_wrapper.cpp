@@ -2,15 +2,51 @@
 #include <iostream>
 #include <sstream>
 #include <memory>
+#include <streambuf>
+#include <cstdlib>
+#include <cstring>
 
 extern "C" {
 
-// Load a TorchScript model from memory buffer
+// Read-only, seekable stream buffer over caller-owned memory, so loading a
+// model from a buffer does not copy it before libtorch reads it
+class MemoryStreamBuffer : public std::streambuf {
+public:
+    MemoryStreamBuffer(const char* data, size_t size) {
+        char* begin = const_cast<char*>(data);
+        setg(begin, begin, begin + size);
+    }
+
+protected:
+    pos_type seekoff(off_type offset, std::ios_base::seekdir dir, std::ios_base::openmode which) override {
+        if (!(which & std::ios_base::in)) {
+            return pos_type(off_type(-1));
+        }
+        off_type base = 0;
+        if (dir == std::ios_base::cur) {
+            base = gptr() - eback();
+        } else if (dir == std::ios_base::end) {
+            base = egptr() - eback();
+        }
+        return seekpos(pos_type(base + offset), which);
+    }
+
+    pos_type seekpos(pos_type position, std::ios_base::openmode which) override {
+        off_type offset = off_type(position);
+        if (!(which & std::ios_base::in) || offset < 0 || offset > egptr() - eback()) {
+            return pos_type(off_type(-1));
+        }
+        setg(eback(), eback() + offset, egptr());
+        return position;
+    }
+};
+
+// Load a TorchScript model from memory buffer. The buffer is read in place;
+// libtorch makes the only copy.
 void* load_torch_module_from_buffer(const char* buffer, long long size) {
     try {
-        // Create a string stream from the buffer
-        std::string model_data(buffer, size);
-        std::istringstream stream(model_data);
+        MemoryStreamBuffer stream_buffer(buffer, static_cast<size_t>(size));
+        std::istream stream(&stream_buffer);
         
         // Load the model from the stream
         torch::jit::script::Module* module = new torch::jit::script::Module(torch::jit::load(stream));
@@ -23,6 +59,36 @@ void* load_torch_module_from_buffer(const char* buffer, long long size) {
     }
 }
 
+// Describe the named parameters of a module, one "name<TAB>dim,dim,..." line
+// per parameter. The caller frees the returned string with free().
+char* get_module_parameter_shapes(void* module) {
+    try {
+        torch::jit::script::Module* mod = static_cast<torch::jit::script::Module*>(module);
+        std::ostringstream out;
+        for (const auto& param : mod->named_parameters(/*recurse=*/true)) {
+            out << param.name << '\t';
+            auto sizes = param.value.sizes();
+            for (size_t i = 0; i < sizes.size(); i++) {
+                if (i > 0) {
+                    out << ',';
+                }
+                out << sizes[i];
+            }
+            out << '\n';
+        }
+        std::string description = out.str();
+        char* result = static_cast<char*>(malloc(description.size() + 1));
+        if (result == nullptr) {
+            return nullptr;
+        }
+        memcpy(result, description.c_str(), description.size() + 1);
+        return result;
+    } catch (const std::exception& e) {
+        std::cerr << "Error listing module parameters: " << e.what() << std::endl;
+        return nullptr;
+    }
+}
+
 // Free a TorchScript module
 void free_torch_module(void* module) {
     if (module) {
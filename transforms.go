@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Transform types supported in FeatureInfo.Transforms
+const (
+	TransformStandardScaler  = "standard_scaler"
+	TransformMinMax          = "min_max"
+	TransformLog1p           = "log1p"
+	TransformClip            = "clip"
+	TransformQuantileBuckets = "quantile_buckets"
+)
+
+// applyTransforms applies a chain of transforms to a numerical value in order
+func applyTransforms(value float64, transforms []FeatureTransform) (float64, error) {
+	for _, transform := range transforms {
+		var err error
+		value, err = applyTransform(value, transform)
+		if err != nil {
+			return 0, fmt.Errorf("%s transform: %v", transform.Type, err)
+		}
+	}
+	return value, nil
+}
+
+// applyTransform applies a single transform, mirroring the scikit-learn and
+// numpy semantics used on the training side
+func applyTransform(value float64, transform FeatureTransform) (float64, error) {
+	switch transform.Type {
+	case TransformStandardScaler:
+		if transform.Mean == nil || transform.Std == nil {
+			return 0, fmt.Errorf("mean and std are required")
+		}
+		// Like StandardScaler, a zero std only centers the value
+		if *transform.Std == 0 {
+			return value - *transform.Mean, nil
+		}
+		return (value - *transform.Mean) / *transform.Std, nil
+
+	case TransformMinMax:
+		if transform.Min == nil || transform.Max == nil {
+			return 0, fmt.Errorf("min and max are required")
+		}
+		// Like MinMaxScaler, a constant feature keeps a scale of 1
+		span := *transform.Max - *transform.Min
+		if span == 0 {
+			return value - *transform.Min, nil
+		}
+		return (value - *transform.Min) / span, nil
+
+	case TransformLog1p:
+		if value <= -1 {
+			return 0, fmt.Errorf("log1p undefined for %g", value)
+		}
+		return math.Log1p(value), nil
+
+	case TransformClip:
+		if transform.Min == nil && transform.Max == nil {
+			return 0, fmt.Errorf("min or max is required")
+		}
+		if transform.Min != nil && value < *transform.Min {
+			value = *transform.Min
+		}
+		if transform.Max != nil && value > *transform.Max {
+			value = *transform.Max
+		}
+		return value, nil
+
+	case TransformQuantileBuckets:
+		if len(transform.Boundaries) == 0 {
+			return 0, fmt.Errorf("boundaries are required")
+		}
+		if !sort.Float64sAreSorted(transform.Boundaries) {
+			return 0, fmt.Errorf("boundaries must be sorted ascending")
+		}
+		// Same as numpy.digitize(value, boundaries): the number of
+		// boundaries less than or equal to the value
+		bucket := sort.Search(len(transform.Boundaries), func(i int) bool {
+			return transform.Boundaries[i] > value
+		})
+		return float64(bucket), nil
+
+	default:
+		return 0, fmt.Errorf("unknown transform type %q", transform.Type)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestApplyTransform(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	boundaries := []float64{1, 2, 2, 3}
+	tests := []struct {
+		name      string
+		transform FeatureTransform
+		input     float64
+		want      float64
+		invalid   bool
+	}{
+		{"standard scaler", FeatureTransform{Type: TransformStandardScaler, Mean: value(10), Std: value(4)}, 14, 1, false},
+		{"standard scaler zero std", FeatureTransform{Type: TransformStandardScaler, Mean: value(10), Std: value(0)}, 14, 4, false},
+		{"standard scaler without std", FeatureTransform{Type: TransformStandardScaler, Mean: value(10)}, 14, 0, true},
+		{"min_max", FeatureTransform{Type: TransformMinMax, Min: value(2), Max: value(6)}, 5, 0.75, false},
+		{"min_max constant feature", FeatureTransform{Type: TransformMinMax, Min: value(3), Max: value(3)}, 3, 0, false},
+		{"min_max constant feature off the constant", FeatureTransform{Type: TransformMinMax, Min: value(3), Max: value(3)}, 5, 2, false},
+		{"log1p", FeatureTransform{Type: TransformLog1p}, math.E - 1, 1, false},
+		{"log1p of a negative input above -1", FeatureTransform{Type: TransformLog1p}, -0.5, math.Log(0.5), false},
+		{"log1p of -1", FeatureTransform{Type: TransformLog1p}, -1, 0, true},
+		{"log1p below -1", FeatureTransform{Type: TransformLog1p}, -3, 0, true},
+		{"clip low", FeatureTransform{Type: TransformClip, Min: value(0), Max: value(1)}, -2, 0, false},
+		{"clip high only", FeatureTransform{Type: TransformClip, Max: value(1)}, 2, 1, false},
+		{"clip without bounds", FeatureTransform{Type: TransformClip}, 2, 0, true},
+		// numpy.digitize(x, [1, 2, 2, 3]) with right=False
+		{"bucketize below", FeatureTransform{Type: TransformQuantileBuckets, Boundaries: boundaries}, 0.5, 0, false},
+		{"bucketize on the first boundary", FeatureTransform{Type: TransformQuantileBuckets, Boundaries: boundaries}, 1, 1, false},
+		{"bucketize between", FeatureTransform{Type: TransformQuantileBuckets, Boundaries: boundaries}, 1.5, 1, false},
+		{"bucketize on a repeated boundary", FeatureTransform{Type: TransformQuantileBuckets, Boundaries: boundaries}, 2, 3, false},
+		{"bucketize on the last boundary", FeatureTransform{Type: TransformQuantileBuckets, Boundaries: boundaries}, 3, 4, false},
+		{"bucketize above", FeatureTransform{Type: TransformQuantileBuckets, Boundaries: boundaries}, 10, 4, false},
+		{"bucketize NaN", FeatureTransform{Type: TransformQuantileBuckets, Boundaries: boundaries}, math.NaN(), 4, false},
+		{"bucketize unsorted", FeatureTransform{Type: TransformQuantileBuckets, Boundaries: []float64{2, 1}}, 1, 0, true},
+		{"unknown type", FeatureTransform{Type: "box_cox"}, 1, 0, true},
+	}
+	for _, tc := range tests {
+		got, err := applyTransform(tc.input, tc.transform)
+		if (err != nil) != tc.invalid {
+			t.Errorf("%s: error = %v, want invalid %t", tc.name, err, tc.invalid)
+			continue
+		}
+		if !tc.invalid && math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestApplyTransformsChains(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	transforms := []FeatureTransform{
+		{Type: TransformClip, Min: value(0)},
+		{Type: TransformLog1p},
+		{Type: TransformStandardScaler, Mean: value(0), Std: value(2)},
+	}
+	got, err := applyTransforms(-5, transforms)
+	if err != nil || got != 0 {
+		t.Errorf("applyTransforms(-5) = %v, %v, want 0", got, err)
+	}
+	if _, err := applyTransforms(-5, transforms[1:]); err == nil {
+		t.Errorf("log1p of -5 without clipping was accepted")
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+import "encoding/json"
+
 // ModelData represents the top-level JSON structure
 type ModelData struct {
 	Interval int    `json:"interval"`
@@ -8,6 +10,9 @@ type ModelData struct {
 
 // TorchModelData represents the inner JSON structure
 type TorchModelData struct {
+	// SchemaVersion is the declared artifact schema version, 0 for
+	// unversioned artifacts; see CurrentSchemaVersion
+	SchemaVersion         int              `json:"schema_version,omitempty"`
 	TorchModel            TorchModel       `json:"torch_model"`
 	FeatureInfo           FeatureInfo      `json:"feature_info"`
 	TaskType              string           `json:"task_type"`
@@ -22,12 +27,47 @@ type TorchModelData struct {
 	ValidationData        []ValidationData `json:"validation_data"`
 	ValidationPredictions []float64        `json:"validation_predictions"`
 	TrainingHistory       TrainingHistory  `json:"training_history"`
+	// ModelSpecificKwargs holds extra keyword arguments the Python side
+	// built the module with, which overlap with TorchModel.Config
+	ModelSpecificKwargs map[string]json.RawMessage `json:"model_specific_kwargs,omitempty"`
+	// ValidationEncodedInputs optionally holds the tensors the Python side fed
+	// the model for ValidationData, for diagnosing encoding divergences
+	ValidationEncodedInputs *EncodedInputs `json:"validation_encoded_inputs,omitempty"`
+	// Integrity optionally holds digests of the model and metadata and a
+	// signature over them
+	Integrity *ArtifactIntegrity `json:"integrity,omitempty"`
+	// Encryption, if set, describes the encrypted parts of the artifact
+	Encryption *ArtifactEncryption `json:"encryption,omitempty"`
+	// EncryptedFeatureInfo replaces FeatureInfo when Encryption.FeatureInfo
+	// is set
+	EncryptedFeatureInfo string `json:"encrypted_feature_info,omitempty"`
+	// ModelBytes holds the decoded, decompressed TorchScript payload when the
+	// loader decoded it directly; TorchModel.Model is then empty
+	ModelBytes []byte `json:"-"`
+	// EncryptedModel holds the decoded ciphertext of an encrypted model
+	// payload when the loader decoded it directly; TorchModel.Model is then
+	// empty
+	EncryptedModel []byte `json:"-"`
+	// LegacyLayout is set when the loader migrated a legacy feature_info
+	// layout into the current types
+	LegacyLayout bool `json:"-"`
+	// Extra holds the top-level fields this tool does not read
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// EncodedInputs holds per-sample encoded model inputs, one row per sample
+type EncodedInputs struct {
+	Numerical   [][]float64 `json:"numerical,omitempty"`
+	Categorical [][]int64   `json:"categorical,omitempty"`
 }
 
 // TorchModel represents the PyTorch model structure
 type TorchModel struct {
 	Model  string `json:"model"`
 	Config string `json:"config"`
+	// Compression is how Model was compressed before base64 encoding:
+	// empty for none or "gzip"
+	Compression string `json:"compression,omitempty"`
 }
 
 // FeatureInfo contains categorical feature mappings
@@ -38,6 +78,20 @@ type FeatureInfo struct {
 	FeatureNames           map[string][]string  `json:"feature_names"`
 	TargetColumn           string               `json:"target_column"`
 	MissingValueHandling   MissingValueHandling `json:"missing_value_handling"`
+	// Transforms lists, per numerical feature, the preprocessing steps applied
+	// in order before the value is fed to the model
+	Transforms map[string][]FeatureTransform `json:"transforms,omitempty"`
+}
+
+// FeatureTransform describes a single numerical preprocessing step.
+// Type is one of standard_scaler, min_max, log1p, clip or quantile_buckets.
+type FeatureTransform struct {
+	Type       string    `json:"type"`
+	Mean       *float64  `json:"mean,omitempty"`
+	Std        *float64  `json:"std,omitempty"`
+	Min        *float64  `json:"min,omitempty"`
+	Max        *float64  `json:"max,omitempty"`
+	Boundaries []float64 `json:"boundaries,omitempty"`
 }
 
 // MissingValueHandling contains label encoders and missing value info
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	windowsPhonePattern = regexp.MustCompile(`(?i)windows phone`)
+	iosVersionPattern   = regexp.MustCompile(`(?:iPhone|CPU|iPad|iPod)(?: iPhone)? OS (\d+)[_.\d]*|\b(?:iOS|iPadOS)[ /](\d+)`)
+	iosDevicePattern    = regexp.MustCompile(`\b(?:iPhone|iPad|iPod|iOS|iPadOS)\b`)
+	androidPattern      = regexp.MustCompile(`\bAndroid\b(?:[ /;]*(\d+)(L)?)?`)
+)
+
+// UserAgentParser derives platform and major_os_version features from
+// User-Agent strings, using the vocabulary of the model's label encoders
+type UserAgentParser struct {
+	platforms  map[string]bool
+	osVersions map[string]bool
+	missing    string
+}
+
+// newUserAgentParser creates a parser that only emits values known to the
+// platform and major_os_version encoders. Without an encoder, any value is kept.
+func newUserAgentParser(featureInfo FeatureInfo) *UserAgentParser {
+	encoders := featureInfo.MissingValueHandling.LabelEncoders
+	return &UserAgentParser{
+		platforms:  classSet(encoders, "platform"),
+		osVersions: classSet(encoders, "major_os_version"),
+		missing:    featureInfo.MissingValueHandling.CategoricalMissingValue,
+	}
+}
+
+// classSet returns the classes of the named encoder as a set, or nil if
+// the encoder does not exist
+func classSet(encoders map[string]LabelEncoder, featureName string) map[string]bool {
+	encoder, exists := encoders[featureName]
+	if !exists {
+		return nil
+	}
+	set := make(map[string]bool, len(encoder.Classes))
+	for _, class := range encoder.Classes {
+		set[class] = true
+	}
+	return set
+}
+
+// Parse returns the platform and major OS version for a User-Agent string.
+// Values that cannot be derived or are not in the encoder vocabulary are
+// reported as the categorical missing value.
+func (p *UserAgentParser) Parse(userAgent string) (platform string, osVersion string) {
+	platform, osVersion = parseUserAgentOS(userAgent)
+	return p.inVocabulary(platform, p.platforms), p.inVocabulary(osVersion, p.osVersions)
+}
+
+// inVocabulary returns value if it is a known class, otherwise the missing value
+func (p *UserAgentParser) inVocabulary(value string, classes map[string]bool) string {
+	if value == "" || (classes != nil && !classes[value]) {
+		return p.missing
+	}
+	return value
+}
+
+// parseUserAgentOS extracts the OS family ("android" or "iOS") and the
+// major OS version from a User-Agent string. Empty strings mean unknown.
+func parseUserAgentOS(userAgent string) (platform string, osVersion string) {
+	// Windows Phone user agents claim Android and iPhone compatibility
+	if windowsPhonePattern.MatchString(userAgent) {
+		return "", ""
+	}
+
+	if match := iosVersionPattern.FindStringSubmatch(userAgent); match != nil {
+		version := match[1]
+		if version == "" {
+			version = match[2]
+		}
+		return "iOS", version
+	}
+	if iosDevicePattern.MatchString(userAgent) {
+		return "iOS", ""
+	}
+
+	if match := androidPattern.FindStringSubmatch(userAgent); match != nil {
+		return "android", match[1] + match[2]
+	}
+
+	return "", ""
+}
+
+// UserAgentCase is one entry of the User-Agent test corpus
+type UserAgentCase struct {
+	UserAgent      string `json:"user_agent"`
+	Platform       string `json:"platform"`
+	MajorOSVersion string `json:"major_os_version"`
+}
+
+// loadUserAgentCorpus reads a JSONL file of User-Agent cases
+func loadUserAgentCorpus(filePath string) ([]UserAgentCase, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open corpus: %w", err)
+	}
+	defer file.Close()
+
+	var cases []UserAgentCase
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var c UserAgentCase
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("corpus line %d: %w", line, err)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read corpus: %w", err)
+	}
+	return cases, nil
+}
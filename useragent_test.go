@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestUserAgentCorpus(t *testing.T) {
+	cases, err := loadUserAgentCorpus("data/useragents.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) == 0 {
+		t.Fatal("empty User-Agent corpus")
+	}
+	parser := newUserAgentParser(artifactFeatureInfo(t))
+	for i, c := range cases {
+		platform, osVersion := parser.Parse(c.UserAgent)
+		if platform != c.Platform || osVersion != c.MajorOSVersion {
+			t.Errorf("case %d: got %s/%s, want %s/%s\n  %s", i+1, platform, osVersion, c.Platform, c.MajorOSVersion, c.UserAgent)
+		}
+	}
+}
+
+func TestUserAgentParserVocabulary(t *testing.T) {
+	featureInfo := FeatureInfo{
+		MissingValueHandling: MissingValueHandling{
+			CategoricalMissingValue: "unknown",
+			LabelEncoders: map[string]LabelEncoder{
+				"platform":         {Classes: []string{"android", "unknown"}},
+				"major_os_version": {Classes: []string{"13", "14"}},
+			},
+		},
+	}
+	tests := []struct {
+		userAgent string
+		platform  string
+		osVersion string
+	}{
+		{"Mozilla/5.0 (Linux; Android 14; SM-S918B)", "android", "14"},
+		{"Mozilla/5.0 (Linux; Android 9; SM-G960F)", "android", "unknown"},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X)", "unknown", "unknown"},
+		{"Mozilla/5.0 (Windows Phone 10.0; Android 6.0.1; Microsoft; Lumia 950)", "unknown", "unknown"},
+		{"", "unknown", "unknown"},
+	}
+	parser := newUserAgentParser(featureInfo)
+	for _, tc := range tests {
+		platform, osVersion := parser.Parse(tc.userAgent)
+		if platform != tc.platform || osVersion != tc.osVersion {
+			t.Errorf("Parse(%q) = %s/%s, want %s/%s", tc.userAgent, platform, osVersion, tc.platform, tc.osVersion)
+		}
+	}
+}
@@ -1,19 +1,36 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
 )
 
-// loadModelData loads and parses the model data from JSON file
+// loadModelData loads and parses the model data from JSON file, optionally
+// gzip-compressed, or from a model directory (see loadModelBundle). The file
+// is streamed, so the model payload ends up decoded in
+// TorchModelData.ModelBytes.
 func loadModelData(filePath string) (*ModelData, *TorchModelData, error) {
-	// Read the JSON file
-	data, err := ioutil.ReadFile(filePath)
+	if isModelBundle(filePath) {
+		return loadModelBundle(filePath)
+	}
+
+	file, sizeHint, err := openArtifact(filePath)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	defer file.Close()
+
+	return loadModelDataStream(file, sizeHint)
+}
 
+// parseModelData parses an artifact held in memory, keeping the base64 model
+// payload in TorchModel.Model
+func parseModelData(data []byte) (*ModelData, *TorchModelData, error) {
 	// Parse the outer JSON
 	var modelData ModelData
 	if err := json.Unmarshal(data, &modelData); err != nil {
@@ -21,12 +38,119 @@ func loadModelData(filePath string) (*ModelData, *TorchModelData, error) {
 	}
 
 	// Parse the inner JSON
-	var torchData TorchModelData
-	if err := json.Unmarshal([]byte(modelData.Data), &torchData); err != nil {
+	torchData, err := unmarshalTorchModelData([]byte(modelData.Data))
+	if err != nil {
 		return nil, nil, fmt.Errorf("failed to parse inner JSON: %w", err)
 	}
 
-	return &modelData, &torchData, nil
+	return &modelData, torchData, nil
+}
+
+// LoadOptions controls how an artifact is loaded
+type LoadOptions struct {
+	// Force loads artifacts that fail the consistency checks, printing the
+	// problems as warnings instead of refusing them. It does not bypass
+	// integrity checks.
+	Force bool
+	// TrustedKeys, if not empty, requires artifacts to be signed by one of
+	// these ed25519 keys, by key ID
+	TrustedKeys map[string]ed25519.PublicKey
+	// KeyProvider supplies the keys of encrypted artifacts, which are
+	// decrypted in memory only
+	KeyProvider KeyProvider
+}
+
+// loadArtifact loads an artifact, decrypts it if needed and runs the
+// consistency checks on it. With trusted keys, the metadata digest and the
+// signature are verified here, so commands that never load the model still
+// refuse unsigned or modified artifacts. Inconsistent artifacts are refused
+// with an *ArtifactError unless forced.
+func loadArtifact(filePath string, options LoadOptions) (*ModelData, *TorchModelData, error) {
+	modelData, torchData, err := loadModelData(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := decryptArtifact(torchData, modelData.Interval, options.KeyProvider); err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt artifact: %w", err)
+	}
+	if len(options.TrustedKeys) > 0 {
+		if err := verifyMetadataIntegrity(torchData, options.TrustedKeys); err != nil {
+			return nil, nil, fmt.Errorf("integrity check failed: %w", err)
+		}
+	}
+
+	if problems := checkArtifact(torchData); len(problems) > 0 {
+		if !options.Force {
+			return nil, nil, &ArtifactError{Problems: problems}
+		}
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "WARNING: artifact: %s\n", problem)
+		}
+	}
+	return modelData, torchData, nil
+}
+
+// decodeModelBytes returns the TorchScript payload of an artifact, decoding
+// and decompressing the base64 model string unless the loader already did
+func decodeModelBytes(torchData *TorchModelData) ([]byte, error) {
+	if torchData.ModelBytes != nil {
+		return torchData.ModelBytes, nil
+	}
+	if torchData.Encryption != nil && torchData.Encryption.Model {
+		return nil, fmt.Errorf("model payload is encrypted")
+	}
+	return decodeModelPayload(torchData.TorchModel.Model, torchData.TorchModel.Compression)
+}
+
+// loadTorchModel decodes the base64 TorchScript payload of an artifact,
+// verifies its digest, loads it and cross-checks its embedding tables
+// against the artifact's vocabulary sizes and configured embedding_dim.
+// Mismatches are refused unless forced. The metadata digest and signature
+// are verified by loadArtifact when trusted keys are configured, and here
+// otherwise.
+func loadTorchModel(torchData *TorchModelData, options LoadOptions) (*TorchModule, error) {
+	modelBytes, err := decodeModelBytes(torchData)
+	if err != nil {
+		return nil, err
+	}
+	// Never hand unverified bytes to libtorch, which executes them
+	if err := verifyModelIntegrity(torchData, modelBytes); err != nil {
+		return nil, fmt.Errorf("integrity check failed: %w", err)
+	}
+	if len(options.TrustedKeys) == 0 {
+		if err := verifyMetadataIntegrity(torchData, nil); err != nil {
+			return nil, fmt.Errorf("integrity check failed: %w", err)
+		}
+	}
+	model, err := loadTorchModuleFromBytes(modelBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := model.Parameters()
+	if err != nil {
+		model.Free()
+		return nil, err
+	}
+	config, err := parseModelConfig(torchData.TorchModel.Config)
+	if err != nil {
+		// The consistency checks report the config itself
+		config = &ModelConfig{}
+	}
+	problems, checked := checkEmbeddingShapes(torchData.FeatureInfo, config, params)
+	if !checked && len(torchData.FeatureInfo.FeatureNames["categorical"]) > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: no per-feature embedding tables found in the module; vocabulary sizes not cross-checked\n")
+	}
+	if len(problems) > 0 {
+		if !options.Force {
+			model.Free()
+			return nil, &ArtifactError{Problems: problems}
+		}
+		for _, problem := range problems {
+			fmt.Fprintf(os.Stderr, "WARNING: model: %s\n", problem)
+		}
+	}
+	return model, nil
 }
 
 // getFeatureValue extracts a feature value from the dynamic ValidationData map
@@ -38,28 +162,121 @@ func getFeatureValue(sample ValidationData, featureName string) (interface{}, er
 	return value, nil
 }
 
-// convertToFloat32 converts various numeric types to float32
-func convertToFloat32(value interface{}) float32 {
+// ConversionMode controls how numerical feature values that cannot be
+// converted to float32 are handled
+type ConversionMode int
+
+const (
+	// LenientConversion warns and substitutes 0 for unconvertible values
+	LenientConversion ConversionMode = iota
+	// StrictConversion rejects unconvertible values with an error
+	StrictConversion
+)
+
+// parseConversionMode parses a conversion mode name ("lenient" or "strict")
+func parseConversionMode(name string) (ConversionMode, error) {
+	switch strings.ToLower(name) {
+	case "lenient":
+		return LenientConversion, nil
+	case "strict":
+		return StrictConversion, nil
+	default:
+		return LenientConversion, fmt.Errorf("unknown conversion mode %q (expected lenient or strict)", name)
+	}
+}
+
+// String returns the name of the conversion mode
+func (m ConversionMode) String() string {
+	if m == StrictConversion {
+		return "strict"
+	}
+	return "lenient"
+}
+
+// convertToFloat64 converts a numerical feature value to float64.
+// All Go integer and float types, json.Number, bool and numeric strings are
+// supported. Strings are parsed with strconv, so parsing never depends on locale.
+func convertToFloat64(value interface{}) (float64, error) {
+	var f float64
 	switch v := value.(type) {
 	case float64:
-		return float32(v)
+		f = v
 	case float32:
-		return v
+		f = float64(v)
 	case int:
-		return float32(v)
+		f = float64(v)
+	case int8:
+		f = float64(v)
+	case int16:
+		f = float64(v)
+	case int32:
+		f = float64(v)
 	case int64:
-		return float32(v)
+		f = float64(v)
+	case uint:
+		f = float64(v)
+	case uint8:
+		f = float64(v)
+	case uint16:
+		f = float64(v)
+	case uint32:
+		f = float64(v)
+	case uint64:
+		f = float64(v)
+	case uintptr:
+		f = float64(v)
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case json.Number:
+		parsed, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid JSON number %q", string(v))
+		}
+		f = parsed
 	case string:
-		// Try to parse string as float
-		if f, err := fmt.Sscanf(v, "%f", new(float64)); err == nil && f == 1 {
-			var result float64
-			fmt.Sscanf(v, "%f", &result)
-			return float32(result)
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("string %q is not a number", v)
 		}
-		return 0.0
+		f = parsed
+	case nil:
+		return 0, fmt.Errorf("value is null")
 	default:
-		return 0.0
+		return 0, fmt.Errorf("unsupported type %T", value)
+	}
+	return f, nil
+}
+
+// convertNumerical converts a numerical feature value like convertToFloat64.
+// Strict conversion also rejects what strconv accepts but the training side
+// does not produce: non-finite values and hexadecimal strings.
+func convertNumerical(value interface{}, mode ConversionMode) (float64, error) {
+	f, err := convertToFloat64(value)
+	if err != nil || mode != StrictConversion {
+		return f, err
+	}
+	if s, isString := value.(string); isString {
+		digits := strings.TrimLeft(strings.TrimSpace(s), "+-")
+		if strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X") {
+			return 0, fmt.Errorf("string %q is a hexadecimal number", s)
+		}
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("value %v is not finite", value)
+	}
+	return f, nil
+}
+
+// narrowToFloat32 converts a float64 to float32, rejecting finite values
+// that are out of float32 range
+func narrowToFloat32(f float64) (float32, error) {
+	if !math.IsInf(f, 0) && math.Abs(f) > math.MaxFloat32 {
+		return 0, fmt.Errorf("value %g overflows float32", f)
 	}
+	return float32(f), nil
 }
 
 // encodeCategorical encodes categorical values using a simple mapping
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestConvertNumerical(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    float64
+		lenient bool
+		strict  bool
+	}{
+		{"float64", 1.5, 1.5, true, true},
+		{"float32", float32(0.25), 0.25, true, true},
+		{"int64", int64(-7), -7, true, true},
+		{"uint8", uint8(200), 200, true, true},
+		{"bool", true, 1, true, true},
+		{"json.Number", json.Number("2.5e3"), 2500, true, true},
+		{"malformed json.Number", json.Number("1.2.3"), 0, false, false},
+		{"numeric string", " -3.25 ", -3.25, true, true},
+		{"exponent string", "1e-3", 0.001, true, true},
+		{"non-numeric string", "abc", 0, false, false},
+		{"decimal comma", "1,5", 0, false, false},
+		{"hex float string", "0x1p4", 16, true, false},
+		{"signed hex float string", "-0X1P4", -16, true, false},
+		{"hex string without exponent", "0x10", 0, false, false},
+		{"NaN string", "NaN", math.NaN(), true, false},
+		{"Inf string", "-Inf", math.Inf(-1), true, false},
+		{"NaN float", math.NaN(), math.NaN(), true, false},
+		{"Inf float", math.Inf(1), math.Inf(1), true, false},
+		{"null", nil, 0, false, false},
+		{"unsupported type", []int{1}, 0, false, false},
+	}
+	for _, tc := range tests {
+		for _, mode := range []ConversionMode{LenientConversion, StrictConversion} {
+			valid := tc.lenient
+			if mode == StrictConversion {
+				valid = tc.strict
+			}
+			got, err := convertNumerical(tc.value, mode)
+			if (err == nil) != valid {
+				t.Errorf("%s (%s): error = %v, want valid %t", tc.name, mode, err, valid)
+				continue
+			}
+			if valid && !(got == tc.want || math.IsNaN(got) && math.IsNaN(tc.want)) {
+				t.Errorf("%s (%s): got %v, want %v", tc.name, mode, got, tc.want)
+			}
+		}
+	}
+}
+
+func TestNarrowToFloat32(t *testing.T) {
+	tests := []struct {
+		value float64
+		valid bool
+	}{
+		{1.5, true},
+		{math.MaxFloat32, true},
+		{-math.MaxFloat32, true},
+		{math.MaxFloat32 * 2, false},
+		{-1e39, false},
+		{math.Inf(1), true},
+		{math.NaN(), true},
+		// Underflow rounds to zero rather than failing
+		{1e-50, true},
+	}
+	for _, tc := range tests {
+		got, err := narrowToFloat32(tc.value)
+		if (err == nil) != tc.valid {
+			t.Errorf("narrowToFloat32(%g): error = %v, want valid %t", tc.value, err, tc.valid)
+			continue
+		}
+		if tc.valid && float64(got) != float64(float32(tc.value)) && !math.IsNaN(tc.value) {
+			t.Errorf("narrowToFloat32(%g) = %g", tc.value, got)
+		}
+	}
+}
+
+func TestEncodeSamplesNamesSampleAndFeature(t *testing.T) {
+	featureInfo := FeatureInfo{
+		FeatureNames: map[string][]string{"numerical": {"price"}, "categorical": {"geo"}},
+		MissingValueHandling: MissingValueHandling{
+			LabelEncoders: map[string]LabelEncoder{"geo": {Classes: []string{"DE", "US"}}},
+		},
+	}
+	tests := []struct {
+		name    string
+		samples []ValidationData
+		mode    ConversionMode
+		want    []string
+	}{
+		{"missing numerical", []ValidationData{{"price": 1, "geo": "US"}, {"geo": "US"}}, LenientConversion, []string{"sample 12", "price"}},
+		{"missing categorical", []ValidationData{{"price": 1}}, LenientConversion, []string{"sample 11", "geo"}},
+		{"unconvertible numerical", []ValidationData{{"price": "abc", "geo": "US"}}, StrictConversion, []string{"sample 11", "price"}},
+	}
+	for _, tc := range tests {
+		_, err := encodeSamples(tc.samples, featureInfo, EncodingOptions{Conversion: tc.mode, SampleOffset: 10})
+		if err == nil {
+			t.Errorf("%s: no error", tc.name)
+			continue
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: error %q does not name %q", tc.name, err, want)
+			}
+		}
+	}
+}